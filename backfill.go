@@ -0,0 +1,252 @@
+/*
+* backfill.go - process historical logs by the embedded timestamps
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the backfill subcommand which reads a log file and
+* its rotated siblings from the beginning, buckets the lines into step
+* windows by the timestamps in log and pushes the historical points
+* log-agent backfill --config config.yaml --from ... --to ... [--log name] [--rate 100]
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	DEFAULT_BACKFILL_RATE = 100
+)
+
+var backfillTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+type RateLimiter struct {
+	Rate  int
+	Sent  int
+	Since time.Time
+}
+
+/*
+* Backfill - the entry of backfill subcommand
+*
+* PARAMS:
+*   - args: command line arguments after the subcommand
+*
+* RETURNS:
+*   - exit code
+ */
+func Backfill(args []string) int {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.StringVar(&configFile, "config", configFile, "configuration file")
+	flags.StringVar(&configFile, "c", configFile, "configuration file (shorthand)")
+	flags.StringVar(&configFormat, "config-format", "", "format of configuration file, 'yaml'/'json'/'toml', by the extension if empty")
+	fromString := flags.String("from", "", "start time of backfill, '2006-01-02 15:04:05' or unix timestamp")
+	toString := flags.String("to", "", "end time of backfill (exclusive), now if empty")
+	name := flags.String("log", "", "name of the log to backfill, all logs if empty")
+	rate := flags.Int("rate", DEFAULT_BACKFILL_RATE, "max data points pushed per second")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	from, err := ParseBackfillTime(*fromString)
+	if err != nil || *fromString == "" {
+		fmt.Fprintf(os.Stderr, "invalid --from %q: %v\n", *fromString, err)
+		return 2
+	}
+	to := time.Now()
+	if *toString != "" {
+		to, err = ParseBackfillTime(*toString)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --to %q: %v\n", *toString, err)
+			return 2
+		}
+	}
+	if !from.Before(to) {
+		fmt.Fprintf(os.Stderr, "--from should be before --to\n")
+		return 2
+	}
+	if *rate <= 0 {
+		fmt.Fprintf(os.Stderr, "--rate should be positive\n")
+		return 2
+	}
+
+	config = LoadConfig()
+	if config == nil {
+		fmt.Fprintf(os.Stderr, "configuration loading FAIL, please check the %s\n", configFile)
+		return 1
+	}
+
+	limiter := &RateLimiter{Rate: *rate, Since: time.Now()}
+
+	code := 0
+	found := false
+	for _, one := range config.Logs {
+		if *name != "" && one.Name != *name {
+			continue
+		}
+		found = true
+
+		if one.Type != "" && one.Type != LOG_TYPE_FILE {
+			log.Printf("log %s is not a file, skip backfill", one.Name)
+			if *name != "" {
+				code = 1
+			}
+			continue
+		}
+
+		if !one.TsEnabled || one.TsPattern == "" {
+			log.Printf("log %s has no timestamp in it, skip backfill", one.Name)
+			if *name != "" {
+				code = 1
+			}
+			continue
+		}
+
+		if err := BackfillLog(one, from.Unix(), to.Unix(), limiter); err != nil {
+			log.Printf("log %s backfill FAIL: %v", one.Name, err)
+			code = 1
+		}
+	}
+
+	if !found {
+		fmt.Fprintf(os.Stderr, "log %s is not found in %s\n", *name, configFile)
+		return 1
+	}
+
+	return code
+}
+
+/*
+* BackfillLog - backfill one log between two timestamps
+*
+* PARAMS:
+*   - one: log configuration
+*   - from: start timestamp
+*   - to: end timestamp, exclusive
+*   - limiter: rate limiter of pushing
+*
+* RETURNS:
+*   - nil: if succeed
+*   - error: if fail
+ */
+func BackfillLog(one LogConfig, from int64, to int64, limiter *RateLimiter) error {
+	agent, err := NewFileAgent(one)
+	if err != nil {
+		return err
+	}
+
+	agent.TsFrom = from
+	agent.TsTo = to
+	agent.Sink = func(data []*FalconData) {
+		limiter.Push(data)
+	}
+
+	for _, filename := range RotatedFiles(one.Path) {
+		// the file was last written before the range, all lines in it are older
+		fileinfo, err := os.Stat(filename)
+		if err != nil || fileinfo.ModTime().Unix() < from {
+			continue
+		}
+
+		log.Printf("backfill %s from %s", one.Name, filename)
+		reader, err := OpenLog(filename)
+		if err != nil {
+			log.Printf("file %s open FAIL: %v", filename, err)
+			continue
+		}
+		err = ReadLines(reader, string(agent.Separator()), agent.CodeUnit, agent.ProcessLine)
+		reader.Close()
+		if err != nil {
+			log.Printf("file %s read FAIL: %v", filename, err)
+		}
+	}
+
+	// report the windows left open
+	agent.Expire(math.MaxInt64)
+
+	return nil
+}
+
+/*
+* ParseBackfillTime - parse the time of command line
+*
+* PARAMS:
+*   - s: time string in local time or unix timestamp
+*
+* RETURNS:
+*   - time.Time, nil: if succeed
+*   - time.Time, error: if fail
+ */
+func ParseBackfillTime(s string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range backfillTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unknown time format")
+}
+
+/*
+* Push - push data to open falcon no faster than the rate
+*
+* RECEIVER: *RateLimiter
+*
+* PARAMS:
+*   - data: data points
+*
+* RETURNS:
+*   No return value
+ */
+func (limiter *RateLimiter) Push(data []*FalconData) {
+	for len(data) > 0 {
+		// wait for the next second when the quota is used up
+		if limiter.Sent >= limiter.Rate {
+			if elapsed := time.Since(limiter.Since); elapsed < time.Second {
+				time.Sleep(time.Second - elapsed)
+			}
+			limiter.Sent = 0
+			limiter.Since = time.Now()
+		}
+
+		size := limiter.Rate - limiter.Sent
+		if size > len(data) {
+			size = len(data)
+		}
+
+		response, err := PushData(config.Falcon.Url, data[:size])
+		if err != nil {
+			log.Printf("push data to falcon FAIL: %v", err)
+		} else {
+			log.Printf("push %d points to falcon succeed: %s", size, string(response))
+		}
+
+		limiter.Sent += size
+		data = data[size:]
+	}
+}
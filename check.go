@@ -0,0 +1,182 @@
+/*
+* check.go - validate the configuration file without running the agent
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the check subcommand which validates the schema of
+* configuration, compiles every regular expression, checks the capture
+* groups of patterns and whether the log paths are readable, and lists
+* all the problems found instead of stopping at the first one
+* log-agent check --config config.yaml
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+/*
+* Check - the entry of check subcommand
+*
+* PARAMS:
+*   - args: command line arguments after the subcommand
+*
+* RETURNS:
+*   - exit code, 0 if the configuration is valid
+ */
+func Check(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.StringVar(&configFile, "config", configFile, "configuration file")
+	flags.StringVar(&configFile, "c", configFile, "configuration file (shorthand)")
+	flags.StringVar(&configFormat, "config-format", "", "format of configuration file, 'yaml'/'json'/'toml', by the extension if empty")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, errs := ParseConfig(true)
+	var warnings []error
+	if cfg != nil {
+		pathErrs, pathWarnings := CheckPaths(cfg)
+		errs = append(errs, pathErrs...)
+		warnings = pathWarnings
+	}
+
+	for _, err := range errs {
+		fmt.Printf("ERROR: %v\n", err)
+	}
+	for _, warning := range warnings {
+		fmt.Printf("WARNING: %v\n", warning)
+	}
+
+	if len(errs) > 0 {
+		fmt.Printf("%s: %d errors, %d warnings\n", configFile, len(errs), len(warnings))
+		return 1
+	}
+
+	items := 0
+	for _, one := range cfg.Logs {
+		items += len(one.Items)
+	}
+	fmt.Printf("%s: OK, %d logs, %d items, %d warnings\n", configFile, len(cfg.Logs), items, len(warnings))
+	return 0
+}
+
+/*
+* CheckPaths - check whether the inputs of logs can be read
+*
+* PARAMS:
+*   - cfg: configuration
+*
+* RETURNS:
+*   - errors: the input can never be read
+*   - warnings: the input can not be read now, but may appear later
+ */
+func CheckPaths(cfg *Config) ([]error, []error) {
+	var errs, warnings []error
+
+	for _, one := range cfg.Logs {
+		switch one.Type {
+		case "", LOG_TYPE_FILE:
+			if one.Path == "" {
+				continue
+			}
+			if _, err := os.Stat(one.Path); os.IsNotExist(err) {
+				// the log may be created after agent starts, but not its directory
+				if _, err := os.Stat(filepath.Dir(one.Path)); err != nil {
+					errs = append(errs, fmt.Errorf("Path of log %s: directory %v", one.Name, err))
+				} else {
+					warnings = append(warnings, fmt.Errorf("Path of log %s: %s does not exist yet", one.Name, one.Path))
+				}
+				continue
+			}
+			if err := CheckReadable(one.Path); err != nil {
+				errs = append(errs, fmt.Errorf("Path of log %s: %v", one.Name, err))
+			}
+		case LOG_TYPE_PIPE:
+			if one.Path == "" || one.Path == STDIN_PATH {
+				continue
+			}
+			fileinfo, err := os.Stat(one.Path)
+			if os.IsNotExist(err) {
+				warnings = append(warnings, fmt.Errorf("Path of log %s: pipe %s does not exist yet", one.Name, one.Path))
+				continue
+			}
+			if err == nil && fileinfo.Mode()&os.ModeNamedPipe == 0 {
+				warnings = append(warnings, fmt.Errorf("Path of log %s: %s is not a named pipe", one.Name, one.Path))
+			}
+			if err := CheckReadable(one.Path); err != nil {
+				errs = append(errs, fmt.Errorf("Path of log %s: %v", one.Name, err))
+			}
+		case LOG_TYPE_CONTAINER:
+			if one.Path == "" {
+				continue
+			}
+			matches, err := filepath.Glob(one.Path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Path of log %s: glob %v", one.Name, err))
+				continue
+			}
+			if len(matches) == 0 {
+				warnings = append(warnings, fmt.Errorf("Path of log %s: no container log matches %s", one.Name, one.Path))
+			}
+			for _, filename := range matches {
+				if err := CheckReadable(filename); err != nil {
+					errs = append(errs, fmt.Errorf("Path of log %s: %v", one.Name, err))
+				}
+			}
+		case LOG_TYPE_JOURNAL:
+			if one.Path == "" {
+				if _, err := exec.LookPath(JOURNAL_COMMAND); err != nil {
+					warnings = append(warnings, fmt.Errorf("log %s: %v", one.Name, err))
+				}
+				continue
+			}
+			if _, err := os.Stat(one.Path); os.IsNotExist(err) {
+				warnings = append(warnings, fmt.Errorf("Path of log %s: %s does not exist yet", one.Name, one.Path))
+				continue
+			}
+			if err := CheckReadable(one.Path); err != nil {
+				errs = append(errs, fmt.Errorf("Path of log %s: %v", one.Name, err))
+			}
+		}
+	}
+
+	return errs, warnings
+}
+
+/*
+* CheckReadable - open the file for reading and close it at once
+*
+* PARAMS:
+*   - path: path of file
+*
+* RETURNS:
+*   - nil, if the file is readable
+*   - error, if not
+ */
+func CheckReadable(path string) error {
+	// non-blocking, a named pipe without writer is not waited for
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fileinfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if fileinfo.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return nil
+}
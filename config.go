@@ -6,6 +6,7 @@
 * 2017/8/18, by Ye Zhiqin, create
 * 2017/9/30, by Ye Zhiqin, modify
 * 2018/1/3, by Ye Zhiqin, modify
+* 2026/10/19, modify
 *
 * DESCRIPTION
 * This file contains the definition of configuration data structure
@@ -16,59 +17,186 @@ package main
 
 import (
 	"crypto/md5"
+	"fmt"
 	"gopkg.in/yaml.v2"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"regexp"
+	"sync"
 )
 
 type Config struct {
-	Falcon FalconConfig `yaml:"falcon"`
-	Logs   []LogConfig  `yaml:"logs"`
+	Falcon   FalconConfig   `yaml:"falcon"`
+	Defaults DefaultsConfig `yaml:"defaults"`
+	Include  string         `yaml:"include"`
+	Logs     []LogConfig    `yaml:"logs"`
+
+	// the valid logs of each included file
+	Included map[string][]LogConfig `yaml:"-"`
 }
 
 type FalconConfig struct {
-	Url      string `yaml:"url"`
-	Endpoint string `yaml:"endpoint"`
+	Url       string `yaml:"url"`
+	Endpoint  string `yaml:"endpoint"`
+	Timestamp string `yaml:"timestamp"`
 }
 
 type LogConfig struct {
-	Name           string       `yaml:"name"`
-	Path           string       `yaml:"path"`
-	Delimiter      string       `yaml:"delimiter"`
-	TsEnabled      bool         `yaml:"tsEnabled"`
-	TsPattern      string       `yaml:"tsPattern"`
-	InotifyEnabled bool         `yaml:"inotifyEnabled"`
-	Items          []ItemConfig `yaml:"items"`
+	Name           string         `yaml:"name"`
+	Type           string         `yaml:"type"`
+	Path           string         `yaml:"path"`
+	Listen         string         `yaml:"listen"`
+	JournalArgs    []string       `yaml:"journalArgs"`
+	CursorFile     string         `yaml:"cursorFile"`
+	Delimiter      string         `yaml:"delimiter"`
+	Encoding       string         `yaml:"encoding"`
+	TsEnabled      bool           `yaml:"tsEnabled"`
+	TsPattern      string         `yaml:"tsPattern"`
+	Lateness       int64          `yaml:"lateness"`
+	StaleAfter     int64          `yaml:"staleAfter"`
+	InotifyEnabled bool           `yaml:"inotifyEnabled"`
+	Defaults       DefaultsConfig `yaml:"defaults"`
+	Items          []ItemConfig   `yaml:"items"`
+}
+
+type DefaultsConfig struct {
+	CounterType string `yaml:"counterType"`
+	Step        int64  `yaml:"step"`
+	Tags        string `yaml:"tags"`
+	Method      string `yaml:"method"`
 }
 
 type ItemConfig struct {
-	Metric      string  `yaml:"metric"`
-	Tags        string  `yaml:"tags"`
-	CounterType string  `yaml:"counterType"`
-	Step        int64   `yaml:"step"`
-	Pattern     string  `yaml:"pattern"`
-	Reversed    bool    `yaml:"reversed"`
-	Threshold   float64 `yaml:"threshold"`
-	Method      string  `yaml:"method"`
+	Metric      string            `yaml:"metric"`
+	Tags        string            `yaml:"tags"`
+	CounterType string            `yaml:"counterType"`
+	Step        int64             `yaml:"step"`
+	Pattern     string            `yaml:"pattern"`
+	Reversed    bool              `yaml:"reversed"`
+	Include     []string          `yaml:"include"`
+	IncludeMode string            `yaml:"includeMode"`
+	Exclude     []string          `yaml:"exclude"`
+	ExcludeMode string            `yaml:"excludeMode"`
+	Threshold   float64           `yaml:"threshold"`
+	Operator    string            `yaml:"operator"`
+	Thresholds  []ThresholdConfig `yaml:"thresholds"`
+	Groups      []string          `yaml:"groups"`
+	Separator   string            `yaml:"separator"`
+	Combine     string            `yaml:"combine"`
+	Transform   TransformConfig   `yaml:"transform"`
+	ZeroFill    *bool             `yaml:"zeroFill"`
+	Match       map[string]string `yaml:"match"`
+	TagFields   []string          `yaml:"tagFields"`
+	Method      string            `yaml:"method"`
+}
+
+type TransformConfig struct {
+	Unit       string  `yaml:"unit"`
+	SourceUnit string  `yaml:"sourceUnit"`
+	Scale      float64 `yaml:"scale"`
+	Offset     float64 `yaml:"offset"`
+	Expr       string  `yaml:"expr"`
 }
 
+type ThresholdConfig struct {
+	Name     string  `yaml:"name"`
+	Operator string  `yaml:"operator"`
+	Value    float64 `yaml:"value"`
+	Lower    float64 `yaml:"lower"`
+	Upper    float64 `yaml:"upper"`
+}
+
+const (
+	LOG_TYPE_FILE      = "file"
+	LOG_TYPE_SYSLOG    = "syslog"
+	LOG_TYPE_PIPE      = "pipe"
+	LOG_TYPE_CONTAINER = "container"
+	LOG_TYPE_JOURNAL   = "journal"
+)
+
 var config *Config
-var configMD5Sum []byte
+var configFile = "config.yaml"
+var configMD5Sums map[string][]byte
+
+// the agents read the falcon configuration while it is replaced by reload
+var configLock sync.RWMutex
+
+/*
+* SetConfig - replace the configuration in use
+*
+* PARAMS:
+*   - cfg: the new configuration
+*
+* RETURNS:
+*   No return value
+ */
+func SetConfig(cfg *Config) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config = cfg
+}
 
 /*
-* CheckConfigMD5 - calculate the md5sum of configuration file
+* CurrentFalcon - the falcon configuration in use
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - FalconConfig: a copy safe to read in the agents
+ */
+func CurrentFalcon() FalconConfig {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return config.Falcon
+}
+
+/*
+* CheckConfigMD5 - calculate the md5sum of configuration file and included files
 *
 * PARAMS:
 * No paramter
 *
 * RETURNS:
+* map[string][]byte, nil, md5sum of each file if succeed
+* nil, error, if fail
+ */
+func CheckConfigMD5() (map[string][]byte, error) {
+	files := []string{configFile}
+	if config != nil {
+		included, err := IncludeFiles(config.Include)
+		if err != nil {
+			log.Printf("include %s FAIL: %v", config.Include, err)
+			return nil, err
+		}
+		files = append(files, included...)
+	}
+
+	md5sums := make(map[string][]byte)
+	for _, file := range files {
+		md5sum, err := FileMD5(file)
+		if err != nil {
+			return nil, err
+		}
+		md5sums[file] = md5sum
+	}
+	return md5sums, nil
+}
+
+/*
+* FileMD5 - calculate the md5sum of a file
+*
+* PARAMS:
+* - file: path of file
+*
+* RETURNS:
 * []byte, nil, if succeed
 * nil, error, if fail
  */
-func CheckConfigMD5() ([]byte, error) {
-	f, err := os.Open("config.yaml")
+func FileMD5(file string) ([]byte, error) {
+	f, err := os.Open(file)
 	if err != nil {
 		log.Printf("configuration file opening FAIL: %v", err)
 		return nil, err
@@ -93,49 +221,280 @@ func CheckConfigMD5() ([]byte, error) {
 * *Config, if succeed
  */
 func LoadConfig() *Config {
-	cfg := new(Config)
-	buf, err := ioutil.ReadFile("config.yaml")
+	buf, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		log.Printf("configuration file reading FAIL: %v", err)
 		return nil
 	}
-	if err := yaml.Unmarshal(buf, cfg); err != nil {
-		log.Printf("yaml file unmarshal FAIL: %v", err)
+	return LoadConfigData(buf)
+}
+
+/*
+* LoadConfigData - load the content of configuration to Config struct
+*
+* PARAMS:
+* - buf: content of configuration file
+*
+* RETURNS:
+* nil, if error ocurred
+* *Config, if succeed
+ */
+func LoadConfigData(buf []byte) *Config {
+	cfg, errs := ParseConfigData(buf, false)
+	if cfg != nil {
+		log.Printf("config: %v", cfg)
+	}
+	fatal := false
+	for _, err := range errs {
+		// the included file is not applied, the others still work
+		if _, ok := err.(*IncludeError); ok {
+			log.Printf("%v, the file is not applied", err)
+			continue
+		}
+		log.Printf("%v", err)
+		fatal = true
+	}
+	if fatal {
 		return nil
 	}
-	log.Printf("config: %v", cfg)
-	// check configuration
+	return cfg
+}
+
+/*
+* ParseConfig - read, unmarshal and validate the configuration file
+*
+* PARAMS:
+* - strict: unknown keys in configuration file are errors
+*
+* RETURNS:
+* nil, []error, if the file can not be read or unmarshaled
+* *Config, []error, the errors found by validation, empty if succeed
+ */
+func ParseConfig(strict bool) (*Config, []error) {
+	buf, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, []error{fmt.Errorf("configuration file reading FAIL: %v", err)}
+	}
+	return ParseConfigData(buf, strict)
+}
+
+/*
+* ParseConfigData - unmarshal and validate the content of configuration
+*
+* PARAMS:
+* - buf: content of configuration file, in the format of configuration file
+* - strict: unknown keys in configuration are errors
+*
+* RETURNS:
+* nil, []error, if the content can not be unmarshaled
+* *Config, []error, the errors found by validation, empty if succeed
+ */
+func ParseConfigData(buf []byte, strict bool) (*Config, []error) {
+	buf, err := ConvertConfig(buf, ConfigFormat(configFile))
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	cfg := new(Config)
+	var errs []error
+	if strict {
+		// unknown keys are reported, the rest is still validated
+		if err := yaml.UnmarshalStrict(buf, new(Config)); err != nil {
+			errs = append(errs, fmt.Errorf("yaml file unmarshal FAIL: %v", err))
+		}
+	}
+	if err := yaml.Unmarshal(buf, cfg); err != nil {
+		if len(errs) == 0 {
+			errs = append(errs, fmt.Errorf("yaml file unmarshal FAIL: %v", err))
+		}
+		return nil, errs
+	}
+	if cfg.Falcon.Timestamp == "" {
+		cfg.Falcon.Timestamp = TIMESTAMP_AT_END
+	}
+	ApplyDefaults(cfg.Logs, cfg.Defaults)
+	errs = append(errs, ExpandConfig(cfg)...)
+	errs = append(errs, ValidateConfig(cfg)...)
+	return cfg, append(errs, LoadIncludes(cfg, strict)...)
+}
+
+/*
+* ValidateConfig - check the configuration, all the errors are collected
+*
+* PARAMS:
+* - cfg: configuration
+*
+* RETURNS:
+* []error, empty if the configuration is valid
+ */
+func ValidateConfig(cfg *Config) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
 	if cfg.Falcon.Url == "" {
-		log.Printf("Url of falcon agent api should not EMPTY!")
-		return nil
+		fail("Url of falcon agent api should not EMPTY!")
+	}
+	if cfg.Falcon.Timestamp != TIMESTAMP_AT_START && cfg.Falcon.Timestamp != TIMESTAMP_AT_END {
+		fail("Timestamp of falcon should be 'start' or 'end'")
+	}
+	return append(errs, ValidateLogs(cfg.Logs)...)
+}
+
+/*
+* ValidateLogs - check the log configurations, all the errors are collected
+*
+* PARAMS:
+* - logs: log configurations
+*
+* RETURNS:
+* []error, empty if the logs are valid
+ */
+func ValidateLogs(logs []LogConfig) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
 	}
-	for _, one := range cfg.Logs {
+
+	names := make(map[string]bool)
+	for i, one := range logs {
 		if one.Name == "" {
-			log.Printf("Name of log should not EMPTY!")
-			return nil
+			fail("Name of log #%d should not EMPTY!", i+1)
+			one.Name = fmt.Sprintf("#%d", i+1)
 		}
-		if one.Path == "" {
-			log.Printf("Path of log should not EMPTY!")
-			return nil
+		if names[one.Name] {
+			fail("Name of log %s is duplicated", one.Name)
 		}
-		for _, item := range one.Items {
+		names[one.Name] = true
+		switch one.Type {
+		case "", LOG_TYPE_FILE, LOG_TYPE_PIPE, LOG_TYPE_CONTAINER:
+			if one.Path == "" {
+				fail("Path of log %s should not EMPTY!", one.Name)
+			}
+		case LOG_TYPE_JOURNAL:
+			// journalctl is run when path is empty
+		case LOG_TYPE_SYSLOG:
+			if _, _, err := ParseListen(one.Listen); err != nil {
+				fail("Listen of log %s: %v", one.Name, err)
+			}
+		default:
+			fail("Type of log %s should be 'file'/'pipe'/'syslog'/'container'/'journal'", one.Name)
+		}
+		if _, err := LookupEncoding(one.Encoding); err != nil {
+			fail("Encoding of log %s: %v", one.Name, err)
+		}
+		if one.StaleAfter < 0 {
+			fail("StaleAfter of log %s should not be negative", one.Name)
+		}
+		if one.Lateness < 0 {
+			fail("Lateness of log %s should not be negative", one.Name)
+		}
+		if one.TsEnabled && one.TsPattern == "" {
+			// only these inputs carry the timestamp of each entry
+			if one.Type != LOG_TYPE_SYSLOG && one.Type != LOG_TYPE_JOURNAL && one.Type != LOG_TYPE_CONTAINER {
+				fail("TsPattern of log %s should not EMPTY when tsEnabled", one.Name)
+			}
+		} else if one.TsEnabled {
+			re, err := regexp.Compile(one.TsPattern)
+			if err != nil {
+				fail("TsPattern of log %s compiling FAIL: %v", one.Name, err)
+			} else if re.NumSubexp() < 6 {
+				// year, month, day, hour, minute and second
+				fail("TsPattern of log %s should have 6 groups, it has %d", one.Name, re.NumSubexp())
+			}
+		}
+		for j, item := range one.Items {
 			if item.Metric == "" {
-				log.Printf("Metric of item should not EMPTY!")
-				return nil
+				fail("Metric of item #%d in log %s should not EMPTY!", j+1, one.Name)
+				item.Metric = fmt.Sprintf("#%d", j+1)
+			}
+			if item.Step <= 0 {
+				fail("Step of item %s should be positive", item.Metric)
 			}
 			if item.CounterType != "GAUGE" && item.CounterType != "COUNTER" {
-				log.Printf("CouterType of item should be 'GAUGE' or 'COUNTER'")
-				return nil
+				fail("CouterType of item %s should be 'GAUGE' or 'COUNTER'", item.Metric)
 			}
-			if item.Pattern == "" {
-				log.Printf("Pattern of item should not EMPTY!")
-				return nil
+			if item.Pattern == "" && (item.Method != "count" || len(item.Include) == 0) {
+				fail("Pattern of item %s should not EMPTY!", item.Metric)
+			}
+			re, err := regexp.Compile(item.Pattern)
+			if err != nil {
+				fail("Pattern of item %s compiling FAIL: %v", item.Metric, err)
+			}
+			if _, err := NewLineFilter(item.Include, item.IncludeMode, item.Exclude, item.ExcludeMode); err != nil {
+				fail("%v", err)
+			}
+			if _, err := NewFieldFilter(item.Match); err != nil {
+				fail("Match of item %s: %v", item.Metric, err)
+			}
+			for _, name := range item.TagFields {
+				if name == "" {
+					fail("TagFields of item %s should not contain EMPTY name", item.Metric)
+				}
 			}
 			if item.Method != "count" && item.Method != "Tcount" && item.Method != "statistic" {
-				log.Printf("Method of item should be 'count'/'Tcount'/'statistic'")
-				return nil
+				fail("Method of item %s should be 'count'/'Tcount'/'statistic'", item.Metric)
+				continue
+			}
+			if item.Method != "count" && re != nil {
+				// the value is captured by a group of pattern
+				if re.NumSubexp() == 0 {
+					fail("Pattern of item %s should have a group to capture the value of %s", item.Metric, item.Method)
+				} else if _, err := NewValueExtractor(re, item); err != nil {
+					fail("%v", err)
+				}
+			}
+			if item.Method == "Tcount" {
+				if err := CheckThresholds(item); err != nil {
+					fail("%v", err)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+/*
+* ApplyDefaults - fill the settings items leave empty with the defaults
+*
+* PARAMS:
+* - logs: log configurations, filled in place
+* - global: defaults of configuration, overridden by the defaults of log
+*
+* RETURNS:
+* No return value
+ */
+func ApplyDefaults(logs []LogConfig, global DefaultsConfig) {
+	for i := range logs {
+		defaults := logs[i].Defaults
+		if defaults.CounterType == "" {
+			defaults.CounterType = global.CounterType
+		}
+		if defaults.Step == 0 {
+			defaults.Step = global.Step
+		}
+		if defaults.Tags == "" {
+			defaults.Tags = global.Tags
+		}
+		if defaults.Method == "" {
+			defaults.Method = global.Method
+		}
+
+		for j := range logs[i].Items {
+			item := &logs[i].Items[j]
+			if item.CounterType == "" {
+				item.CounterType = defaults.CounterType
+			}
+			if item.Step == 0 {
+				item.Step = defaults.Step
+			}
+			if item.Tags == "" {
+				item.Tags = defaults.Tags
+			}
+			if item.Method == "" {
+				item.Method = defaults.Method
 			}
 		}
 	}
-	return cfg
 }
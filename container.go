@@ -0,0 +1,379 @@
+/*
+* container.go - docker json-file and CRI log input
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the container input which tails all the container
+* log files matching the path glob, unwraps the docker json-file or CRI
+* envelope of each line, joins the partial lines and matches the inner
+* message, container/pod/namespace/stream are the fields of entry
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// seconds between two globs of the container log files
+	CONTAINER_SCAN_INTERVAL = 5
+)
+
+var defaultContainerTagFields = []string{"namespace", "pod", "container"}
+
+// /var/log/containers/<pod>_<namespace>_<container>-<id>.log
+var containerLinkRe = regexp.MustCompile(`^([^_]+)_([^_]+)_(.+)-([0-9a-f]{64})\.log$`)
+
+// /var/lib/docker/containers/<id>/<id>-json.log
+var dockerIdRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+type ContainerFile struct {
+	Agent   *FileAgent
+	Fields  map[string]string
+	Partial map[string][]byte
+}
+
+type dockerLine struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+type dockerConfig struct {
+	Name   string `json:"Name"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+/*
+* TailContainers - tail the container log files in a loop
+*
+* PARAMS:
+*   - fa: file agent of container input
+*   - finish: a channel to receiver stop signal
+*
+* RETURNS:
+*   No return value
+ */
+func TailContainers(fa *FileAgent, finish <-chan bool) {
+	log.Printf("agent for %s is launching...", fa.Filename)
+
+	// create one second ticker
+	ticker := time.NewTicker(time.Second * 1)
+	defer ticker.Stop()
+
+	// the lines written before agent starts are skipped as the plain file
+	fa.ResetTasks()
+	fa.Discover(true)
+	scanTime := time.Now().Unix()
+
+TAIL:
+	for {
+		select {
+		case <-finish:
+			for filename, cf := range fa.Containers {
+				cf.Agent.FileClose()
+				delete(fa.Containers, filename)
+			}
+			break TAIL
+		case <-ticker.C:
+			if now := time.Now().Unix(); now-scanTime >= CONTAINER_SCAN_INTERVAL {
+				fa.Discover(false)
+				scanTime = now
+			}
+			fa.Timeup()
+		default:
+			for _, cf := range fa.Containers {
+				cf.Agent.TryReading()
+			}
+			time.Sleep(time.Millisecond * 100)
+		}
+	}
+
+	wg.Done()
+	log.Printf("agent for %s is exiting...", fa.Filename)
+}
+
+/*
+* Discover - glob the container log files, add the new ones and drop the gone ones
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - initial: true when agent starts, the existing content is skipped
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) Discover(initial bool) {
+	if fa.Containers == nil {
+		fa.Containers = make(map[string]*ContainerFile)
+	}
+
+	matches, err := filepath.Glob(fa.Filename)
+	if err != nil {
+		log.Printf("glob %s FAIL: %v", fa.Filename, err)
+		return
+	}
+
+	found := make(map[string]bool)
+	for _, filename := range matches {
+		found[filename] = true
+		if _, ok := fa.Containers[filename]; ok {
+			continue
+		}
+
+		cf := fa.NewContainerFile(filename)
+		if err := cf.Agent.FileOpen(); err != nil {
+			continue
+		}
+
+		// a container started after agent, read its log from start
+		if !initial {
+			if _, err := cf.Agent.File.Seek(0, os.SEEK_SET); err != nil {
+				log.Printf("seek file %s FAIL: %v", filename, err)
+			}
+			cf.Agent.LastOffset = 0
+			if err := cf.Agent.ReadRemainder(); err != nil {
+				log.Printf("file %s reading FAIL: %v", filename, err)
+			}
+		}
+
+		log.Printf("container log %s is found: %v", filename, cf.Fields)
+		fa.Containers[filename] = cf
+	}
+
+	// the container is removed, read the rest and forget it
+	for filename, cf := range fa.Containers {
+		if found[filename] {
+			continue
+		}
+		log.Printf("container log %s is gone", filename)
+		cf.Agent.Drain()
+		cf.Agent.FileClose()
+		fa.ProcessContainerLine(cf, nil)
+		delete(fa.Containers, filename)
+	}
+}
+
+/*
+* NewContainerFile - generate the reader of a container log file
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - filename: path of container log file
+*
+* RETURNS:
+*   - *ContainerFile
+ */
+func (fa *FileAgent) NewContainerFile(filename string) *ContainerFile {
+	cf := new(ContainerFile)
+	cf.Fields = ContainerFields(filename)
+	cf.Partial = make(map[string][]byte)
+
+	// the file agent only keeps the reading state, lines go to the owner
+	agent := new(FileAgent)
+	agent.Name = fa.Name
+	agent.Type = LOG_TYPE_FILE
+	agent.Filename = filename
+	agent.Delimiter = "\n"
+	agent.Forward = func(line []byte) {
+		fa.ProcessContainerLine(cf, line)
+	}
+	cf.Agent = agent
+
+	return cf
+}
+
+/*
+* ContainerFields - find container/pod/namespace by the log path
+*
+* PARAMS:
+*   - filename: path of container log file
+*
+* RETURNS:
+*   - map[string]string: fields of container, unknown ones are absent
+ */
+func ContainerFields(filename string) map[string]string {
+	fields := make(map[string]string)
+
+	dir := filepath.Dir(filename)
+	base := filepath.Base(filename)
+
+	// /var/log/pods/<namespace>_<pod>_<uid>/<container>/<restart>.log
+	if parts := strings.Split(filepath.Base(filepath.Dir(dir)), "_"); len(parts) == 3 {
+		fields["namespace"] = parts[0]
+		fields["pod"] = parts[1]
+		fields["container"] = filepath.Base(dir)
+		return fields
+	}
+
+	if matches := containerLinkRe.FindStringSubmatch(base); matches != nil {
+		fields["pod"] = matches[1]
+		fields["namespace"] = matches[2]
+		fields["container"] = matches[3]
+		return fields
+	}
+
+	// docker keeps the name and labels beside the log
+	if id := filepath.Base(dir); dockerIdRe.MatchString(id) {
+		fields["container"] = id[:12]
+
+		buf, err := ioutil.ReadFile(filepath.Join(dir, "config.v2.json"))
+		if err != nil {
+			return fields
+		}
+		var cfg dockerConfig
+		if err := json.Unmarshal(buf, &cfg); err != nil {
+			return fields
+		}
+
+		if name := strings.TrimPrefix(cfg.Name, "/"); name != "" {
+			fields["container"] = name
+		}
+		if name, ok := cfg.Config.Labels["io.kubernetes.container.name"]; ok {
+			fields["container"] = name
+		}
+		if pod, ok := cfg.Config.Labels["io.kubernetes.pod.name"]; ok {
+			fields["pod"] = pod
+		}
+		if namespace, ok := cfg.Config.Labels["io.kubernetes.pod.namespace"]; ok {
+			fields["namespace"] = namespace
+		}
+	}
+
+	return fields
+}
+
+/*
+* ParseContainerLine - unwrap the docker json-file or CRI envelope
+*
+* PARAMS:
+*   - line: one line of container log file
+*
+* RETURNS:
+*   - message, stream, timestamp, partial, true: if succeed
+*   - nil, "", 0, false, false: if the line is broken
+ */
+func ParseContainerLine(line []byte) ([]byte, string, int64, bool, bool) {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil, "", 0, false, false
+	}
+
+	// {"log":"message\n","stream":"stdout","time":"..."}
+	if line[0] == '{' {
+		var entry dockerLine
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, "", 0, false, false
+		}
+
+		var ts int64
+		if t, err := time.Parse(time.RFC3339Nano, entry.Time); err == nil {
+			ts = t.Unix()
+		}
+
+		// docker splits long lines, only the last part ends with newline
+		partial := !strings.HasSuffix(entry.Log, "\n")
+		message := strings.TrimSuffix(entry.Log, "\n")
+		return []byte(message), entry.Stream, ts, partial, true
+	}
+
+	// <time> <stream> <P|F> message
+	parts := bytes.SplitN(line, []byte(" "), 4)
+	if len(parts) < 3 {
+		return nil, "", 0, false, false
+	}
+
+	var ts int64
+	if t, err := time.Parse(time.RFC3339Nano, string(parts[0])); err == nil {
+		ts = t.Unix()
+	} else {
+		return nil, "", 0, false, false
+	}
+
+	var message []byte
+	if len(parts) == 4 {
+		message = parts[3]
+	}
+	partial := bytes.HasPrefix(parts[2], []byte("P"))
+	return message, string(parts[1]), ts, partial, true
+}
+
+/*
+* ProcessContainerLine - join the partial lines and match the message
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - cf: container log file of the line
+*   - line: one line of container log file, nil to flush the partial one
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) ProcessContainerLine(cf *ContainerFile, line []byte) {
+	if line == nil {
+		var streams []string
+		for stream := range cf.Partial {
+			streams = append(streams, stream)
+		}
+		sort.Strings(streams)
+		for _, stream := range streams {
+			fa.MatchEntry(cf.Partial[stream], cf.StreamFields(stream), 0)
+			delete(cf.Partial, stream)
+		}
+		return
+	}
+
+	fa.LastData = time.Now().Unix()
+
+	message, stream, ts, partial, ok := ParseContainerLine(line)
+	if !ok {
+		return
+	}
+
+	// stdout and stderr are interleaved, each joins its own partial lines
+	cf.Partial[stream] = append(cf.Partial[stream], message...)
+	if partial && len(cf.Partial[stream]) <= MAX_LINE_SIZE {
+		return
+	}
+
+	fa.MatchEntry(cf.Partial[stream], cf.StreamFields(stream), ts)
+	delete(cf.Partial, stream)
+}
+
+/*
+* StreamFields - the fields of an entry from the stream
+*
+* RECEIVER: *ContainerFile
+*
+* PARAMS:
+*   - stream: stdout or stderr
+*
+* RETURNS:
+*   - map[string]string: fields of container with the stream
+ */
+func (cf *ContainerFile) StreamFields(stream string) map[string]string {
+	fields := make(map[string]string, len(cf.Fields)+1)
+	for key, value := range cf.Fields {
+		fields[key] = value
+	}
+	fields["stream"] = stream
+	return fields
+}
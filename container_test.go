@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestContainerStreamsInterleaved(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	var items []ItemConfig
+	for _, item := range []struct {
+		metric  string
+		pattern string
+		stream  string
+	}{
+		{"stdout.error", "^ERROR disk full$", "stdout"},
+		{"stderr.warn", "^WARN slow$", "stderr"},
+		{"stderr.rest", "^WARN cut$", "stderr"},
+		{"mixed", "ERROR disk WARN|WARN slowfull", ".*"},
+	} {
+		items = append(items, ItemConfig{
+			Metric:      item.metric,
+			Pattern:     item.pattern,
+			Method:      "count",
+			Step:        60,
+			CounterType: "GAUGE",
+			Match:       map[string]string{"stream": item.stream},
+			TagFields:   []string{},
+		})
+	}
+	agent, err := NewFileAgent(LogConfig{Name: "test", Type: LOG_TYPE_CONTAINER, Path: "/var/log/containers/*.log", Items: items})
+	if err != nil {
+		t.Fatalf("NewFileAgent: %v", err)
+	}
+
+	cf := agent.NewContainerFile("/var/log/containers/web-0_default_nginx-" + strings.Repeat("a", 64) + ".log")
+	lines := []string{
+		"2026-10-19T10:00:00.000000000Z stdout P ERROR disk ",
+		"2026-10-19T10:00:00.100000000Z stderr F WARN slow",
+		"2026-10-19T10:00:00.200000000Z stdout F full",
+		"2026-10-19T10:00:00.300000000Z stderr P WARN cut",
+	}
+	for _, line := range lines {
+		agent.ProcessContainerLine(cf, []byte(line))
+	}
+	// the partial stderr line is flushed as stderr
+	agent.ProcessContainerLine(cf, nil)
+
+	for idx, want := range []int64{1, 1, 1, 0} {
+		var count int64
+		for _, window := range agent.Tasks[idx].Windows {
+			count += window.ValueCnt
+		}
+		if count != want {
+			t.Errorf("%s counts %d, want %d", items[idx].Metric, count, want)
+		}
+	}
+	if cf.Fields["stream"] != "" {
+		t.Errorf("fields of container file are changed: %v", cf.Fields)
+	}
+}
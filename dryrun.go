@@ -0,0 +1,307 @@
+/*
+* dryrun.go - match the items of a log against a sample file offline
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the test subcommand which feeds a sample file to the
+* agent of a log, with the real matching and reporting but without pushing,
+* and prints for each item the matched lines, the extracted values, some
+* unmatched lines and the data points which would be pushed in each window
+* log-agent test --config config.yaml --log name --file sample.log
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// the unmatched lines and values printed for each item
+	DRYRUN_EXAMPLES = 5
+	DRYRUN_VALUES   = 10
+)
+
+type TaskTrace struct {
+	Matched   int64
+	Values    []float64
+	ValueCnt  int64
+	ValueMax  float64
+	ValueMin  float64
+	ValueSum  float64
+	Unmatched int64
+	Examples  []string
+	Windows   []*TraceWindow
+}
+
+type TraceWindow struct {
+	Window *Window
+	Tags   string
+	Points []*FalconData
+}
+
+/*
+* Hit - record a line matched by task
+*
+* RECEIVER: *TaskTrace
+*
+* PARAMS:
+*   - value: value extracted from line, 0 for count
+*
+* RETURNS:
+*   No return value
+ */
+func (trace *TaskTrace) Hit(value float64) {
+	if trace == nil {
+		return
+	}
+	trace.Matched += 1
+	if len(trace.Values) < DRYRUN_VALUES {
+		trace.Values = append(trace.Values, value)
+	}
+	if trace.ValueCnt == 0 || value > trace.ValueMax {
+		trace.ValueMax = value
+	}
+	if trace.ValueCnt == 0 || value < trace.ValueMin {
+		trace.ValueMin = value
+	}
+	trace.ValueCnt += 1
+	trace.ValueSum += value
+}
+
+/*
+* Miss - record a line not matched by task
+*
+* RECEIVER: *TaskTrace
+*
+* PARAMS:
+*   - line: the line
+*
+* RETURNS:
+*   No return value
+ */
+func (trace *TaskTrace) Miss(line []byte) {
+	if trace == nil {
+		return
+	}
+	trace.Unmatched += 1
+	if len(trace.Examples) < DRYRUN_EXAMPLES {
+		trace.Examples = append(trace.Examples, strings.TrimRight(string(line), "\r\n"))
+	}
+}
+
+/*
+* Report - record the data points of a closed window
+*
+* RECEIVER: *TaskTrace
+*
+* PARAMS:
+*   - window: closed window
+*   - tags: tags of the series
+*   - points: data points of window
+*
+* RETURNS:
+*   No return value
+ */
+func (trace *TaskTrace) Report(window *Window, tags string, points []*FalconData) {
+	if trace == nil || len(points) == 0 {
+		return
+	}
+	trace.Windows = append(trace.Windows, &TraceWindow{Window: window, Tags: tags, Points: points})
+}
+
+/*
+* DryRun - the entry of test subcommand
+*
+* PARAMS:
+*   - args: command line arguments after the subcommand
+*
+* RETURNS:
+*   - exit code
+ */
+func DryRun(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.StringVar(&configFile, "config", configFile, "configuration file")
+	flags.StringVar(&configFile, "c", configFile, "configuration file (shorthand)")
+	flags.StringVar(&configFormat, "config-format", "", "format of configuration file, 'yaml'/'json'/'toml', by the extension if empty")
+	name := flags.String("log", "", "name of the log to test, may be empty if there is only one log")
+	filename := flags.String("file", "", "sample file, the path of log if empty")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	config = LoadConfig()
+	if config == nil {
+		fmt.Fprintf(os.Stderr, "configuration loading FAIL, please check the %s\n", configFile)
+		return 1
+	}
+
+	var one *LogConfig
+	for idx := range config.Logs {
+		if config.Logs[idx].Name == *name || (*name == "" && len(config.Logs) == 1) {
+			one = &config.Logs[idx]
+			break
+		}
+	}
+	if one == nil {
+		if *name == "" {
+			fmt.Fprintf(os.Stderr, "--log is required, there are %d logs in %s\n", len(config.Logs), configFile)
+		} else {
+			fmt.Fprintf(os.Stderr, "log %s is not found in %s\n", *name, configFile)
+		}
+		return 2
+	}
+	if *filename == "" {
+		*filename = one.Path
+	}
+
+	agent, err := NewFileAgent(*one)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agent of %s creating FAIL: %v\n", one.Name, err)
+		return 1
+	}
+	for _, task := range agent.Tasks {
+		task.Trace = new(TaskTrace)
+	}
+	// nothing is pushed, the points are kept in the traces
+	agent.Sink = func(data []*FalconData) {}
+
+	lines, err := agent.DryRunFile(*filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "file %s reading FAIL: %v\n", *filename, err)
+		return 1
+	}
+
+	fmt.Printf("log %s (%s): %s, %d lines\n", agent.Name, agent.Type, *filename, lines)
+	for _, task := range agent.Tasks {
+		PrintTrace(task)
+	}
+
+	return 0
+}
+
+/*
+* DryRunFile - feed the sample file to agent as its input would
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - filename: sample file
+*
+* RETURNS:
+*   - lines, nil: number of lines or entries read, if succeed
+*   - lines, error: if fail
+ */
+func (fa *FileAgent) DryRunFile(filename string) (int64, error) {
+	reader, err := OpenLog(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	var lines int64
+	fa.ResetTasks()
+
+	// a line missed by the tasks which did not count it
+	feed := func(line []byte, process func()) {
+		lines += 1
+		matched := make([]int64, len(fa.Tasks))
+		for idx, task := range fa.Tasks {
+			matched[idx] = task.Trace.Matched
+		}
+		process()
+		for idx, task := range fa.Tasks {
+			if task.Trace.Matched == matched[idx] {
+				task.Trace.Miss(line)
+			}
+		}
+	}
+
+	switch fa.Type {
+	case LOG_TYPE_JOURNAL:
+		entries := make(chan *JournalEntry, 16)
+		done := make(chan bool)
+		defer close(done)
+		go ReadJournal(reader, entries, done)
+		for entry := range entries {
+			feed(entry.Message, func() { fa.ProcessJournal(entry) })
+		}
+	case LOG_TYPE_SYSLOG:
+		err = ReadLines(reader, "\n", 1, func(line []byte) {
+			feed(line, func() { fa.ProcessSyslog(line) })
+		})
+	case LOG_TYPE_CONTAINER:
+		cf := fa.NewContainerFile(filename)
+		err = ReadLines(reader, "\n", 1, func(line []byte) {
+			feed(line, func() { fa.ProcessContainerLine(cf, line) })
+		})
+		fa.ProcessContainerLine(cf, nil)
+	default:
+		err = ReadLines(reader, string(fa.Separator()), fa.CodeUnit, func(line []byte) {
+			feed(line, func() { fa.ProcessLine(line) })
+		})
+	}
+
+	// report the windows left open
+	fa.Expire(math.MaxInt64)
+
+	return lines, err
+}
+
+/*
+* PrintTrace - print what a task did with the sample
+*
+* PARAMS:
+*   - task: agent task with trace
+*
+* RETURNS:
+*   No return value
+ */
+func PrintTrace(task *AgentTask) {
+	trace := task.Trace
+
+	fmt.Printf("\nitem %s (%s): pattern %q\n", task.Metric, task.Method, task.Pattern)
+	fmt.Printf("  matched: %d lines\n", trace.Matched)
+
+	if task.Method != "count" && trace.ValueCnt > 0 {
+		var values []string
+		for _, value := range trace.Values {
+			values = append(values, fmt.Sprintf("%v", value))
+		}
+		if trace.ValueCnt > int64(len(trace.Values)) {
+			values = append(values, "...")
+		}
+		fmt.Printf("  values: min %v, max %v, avg %v: %s\n",
+			trace.ValueMin, trace.ValueMax, trace.ValueSum/float64(trace.ValueCnt), strings.Join(values, " "))
+	}
+
+	fmt.Printf("  unmatched: %d lines\n", trace.Unmatched)
+	for _, example := range trace.Examples {
+		fmt.Printf("    | %s\n", example)
+	}
+
+	for _, tw := range trace.Windows {
+		start := time.Unix(tw.Window.TsStart, 0).Format("2006-01-02 15:04:05")
+		end := time.Unix(tw.Window.TsEnd, 0).Format("15:04:05")
+		if tw.Tags != "" {
+			fmt.Printf("  window %s ~ %s, tags %s:\n", start, end, tw.Tags)
+		} else {
+			fmt.Printf("  window %s ~ %s:\n", start, end)
+		}
+		for _, point := range tw.Points {
+			fmt.Printf("    %s = %v (%s, timestamp %d, step %d)\n",
+				point.Metric, point.Value, point.CounterType, point.Timestamp, point.Step)
+		}
+	}
+}
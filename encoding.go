@@ -0,0 +1,212 @@
+/*
+* encoding.go - character encoding of non-UTF-8 logs
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the functions to look up the character encoding
+* of a log, encode the delimiter into it, split the lines on the code unit
+* boundaries and decode each line to UTF-8 before matching
+* utf-16 without byte order is little-endian, the byte order mark is not
+* used to tell it, big-endian logs should be set to utf-16be
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+/*
+* LookupEncoding - find the character encoding by name
+*
+* PARAMS:
+*   - name: 'utf-8'/'gbk'/'gb18030'/'utf-16'/'utf-16le'/'utf-16be'
+*
+* RETURNS:
+*   - encoding.Encoding, nil: if succeed, nil encoding for UTF-8
+*   - nil, error: if the encoding is not supported
+ */
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(name) {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	case "gbk", "gb2312":
+		return simplifiedchinese.GBK, nil
+	case "gb18030":
+		return simplifiedchinese.GB18030, nil
+	case "utf-16", "utf-16le", "utf16", "utf16le":
+		// the lines are split before decoding, the byte order can not change halfway
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil
+	case "utf-16be", "utf16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+	}
+	return nil, fmt.Errorf("encoding %s is not supported", name)
+}
+
+/*
+* CodeUnit - the size of code unit of an encoding
+*
+* PARAMS:
+*   - name: name of encoding
+*
+* RETURNS:
+*   - 2 for UTF-16, 1 for the others
+ */
+func CodeUnit(name string) int {
+	switch strings.ToLower(name) {
+	case "utf-16", "utf-16le", "utf16", "utf16le", "utf-16be", "utf16be":
+		return 2
+	}
+	return 1
+}
+
+/*
+* IndexSeparator - find the first separator beginning on a code unit boundary
+*
+* PARAMS:
+*   - data: bytes in the encoding of log
+*   - sep: encoded delimiter
+*   - unit: size of code unit
+*
+* RETURNS:
+*   - index of separator, -1 if not found
+ */
+func IndexSeparator(data []byte, sep []byte, unit int) int {
+	if unit <= 1 {
+		return bytes.Index(data, sep)
+	}
+
+	offset := 0
+	for {
+		idx := bytes.Index(data[offset:], sep)
+		if idx < 0 {
+			return -1
+		}
+		if (offset+idx)%unit == 0 {
+			return offset + idx
+		}
+		// the match crosses two code units, search from the next unit
+		offset += idx + unit - (offset+idx)%unit
+	}
+}
+
+/*
+* SplitLines - split data after each separator, as bytes.SplitAfter
+*
+* PARAMS:
+*   - data: bytes in the encoding of log
+*   - sep: encoded delimiter
+*   - unit: size of code unit
+*
+* RETURNS:
+*   - [][]byte: lines with separator, the last one is the rest without it
+ */
+func SplitLines(data []byte, sep []byte, unit int) [][]byte {
+	var lines [][]byte
+	for {
+		idx := IndexSeparator(data, sep, unit)
+		if idx < 0 {
+			return append(lines, data)
+		}
+		lines = append(lines, data[:idx+len(sep)])
+		data = data[idx+len(sep):]
+	}
+}
+
+/*
+* Separator - the delimiter in the encoding of log
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - []byte: encoded delimiter
+ */
+func (fa *FileAgent) Separator() []byte {
+	if fa.Delimiter == "" {
+		fa.Delimiter = "\n"
+	}
+
+	if fa.Encoding == nil {
+		return []byte(fa.Delimiter)
+	}
+
+	sep, err := fa.Encoding.NewEncoder().Bytes([]byte(fa.Delimiter))
+	if err != nil {
+		return []byte(fa.Delimiter)
+	}
+	return sep
+}
+
+/*
+* Decode - decode raw bytes of log to UTF-8
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - raw: bytes in the encoding of log
+*
+* RETURNS:
+*   - []byte, nil: UTF-8 bytes if succeed
+*   - nil, error: if fail
+ */
+func (fa *FileAgent) Decode(raw []byte) ([]byte, error) {
+	if fa.Encoding == nil {
+		return raw, nil
+	}
+
+	if fa.Decoder == nil {
+		fa.Decoder = fa.Encoding.NewDecoder()
+	}
+
+	decoded, err := fa.Decoder.Bytes(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// byte order mark at the head of file
+	return bytes.TrimPrefix(decoded, utf8BOM), nil
+}
+
+/*
+* ProcessLine - decode a line to UTF-8 and match it
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - line: a raw line of log file
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) ProcessLine(line []byte) {
+	if fa.Forward != nil {
+		fa.Forward(line)
+		return
+	}
+
+	decoded, err := fa.Decode(line)
+	if err != nil {
+		// counted in agent.decode.failed, logged once a status step
+		if fa.DecodeFail == 0 {
+			log.Printf("line decoding FAIL: %v", err)
+		}
+		fa.DecodeFail += 1
+		return
+	}
+	fa.MatchLine(decoded)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+func newEncodingAgent(t *testing.T, name string) *FileAgent {
+	enc, err := LookupEncoding(name)
+	if err != nil {
+		t.Fatalf("LookupEncoding(%s): %v", name, err)
+	}
+	return &FileAgent{Delimiter: "\n", Encoding: enc, CodeUnit: CodeUnit(name)}
+}
+
+func encodeLines(t *testing.T, fa *FileAgent, text string) []byte {
+	raw, err := fa.Encoding.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("encoding %q: %v", text, err)
+	}
+	return raw
+}
+
+func TestSplitLinesUTF16(t *testing.T) {
+	cases := []struct {
+		encoding string
+		text     string
+	}{
+		// U+0A41 U+4E00 is 41 0a 00 4e in little-endian, '\n' is 0a 00
+		{"utf-16le", "ੁ一 ERROR\n第二行\n"},
+		// U+4E00 U+0A41 is 4e 00 0a 41 in big-endian, '\n' is 00 0a
+		{"utf-16be", "一ੁ ERROR\n第二行\n"},
+	}
+
+	for _, c := range cases {
+		fa := newEncodingAgent(t, c.encoding)
+		raw := encodeLines(t, fa, c.text)
+		sep := fa.Separator()
+
+		if bytes.Index(raw, sep) >= bytes.Index(raw, encodeLines(t, fa, " ")) {
+			t.Fatalf("%s: the sample has no separator crossing code units", c.encoding)
+		}
+
+		lines := SplitLines(raw, sep, fa.CodeUnit)
+		if len(lines) != 3 || len(lines[2]) != 0 {
+			t.Fatalf("%s: %d lines, want 2 and an empty rest", c.encoding, len(lines))
+		}
+
+		var decoded []string
+		for _, line := range lines[:2] {
+			one, err := fa.Decode(line)
+			if err != nil {
+				t.Fatalf("%s: decoding %x: %v", c.encoding, line, err)
+			}
+			decoded = append(decoded, string(one))
+		}
+		if decoded[0] != c.text[:len(c.text)-len("第二行\n")] || decoded[1] != "第二行\n" {
+			t.Errorf("%s: lines %q", c.encoding, decoded)
+		}
+
+		// the same split when the lines are read from a stream
+		var read []string
+		err := ReadLines(bytes.NewReader(raw), string(sep), fa.CodeUnit, func(line []byte) {
+			one, _ := fa.Decode(line)
+			read = append(read, string(one))
+		})
+		if err != nil || len(read) != 2 || read[0] != decoded[0] {
+			t.Errorf("%s: ReadLines %q, %v", c.encoding, read, err)
+		}
+	}
+}
+
+func TestIndexSeparator(t *testing.T) {
+	cases := []struct {
+		data string
+		sep  string
+		unit int
+		idx  int
+	}{
+		{"ab\ncd", "\n", 1, 2},
+		{"\x41\x0a\x00\x4e\x0a\x00", "\n\x00", 2, 4},
+		{"\x41\x0a\x00\x4e", "\n\x00", 2, -1},
+		{"\x0a\x00", "\n\x00", 2, 0},
+		{"", "\n\x00", 2, -1},
+	}
+
+	for _, c := range cases {
+		if idx := IndexSeparator([]byte(c.data), []byte(c.sep), c.unit); idx != c.idx {
+			t.Errorf("IndexSeparator(%x, %x, %d) = %d, want %d", c.data, c.sep, c.unit, idx, c.idx)
+		}
+	}
+}
+
+func TestDecodeBOM(t *testing.T) {
+	for _, name := range []string{"utf-16le", "utf-16be"} {
+		fa := newEncodingAgent(t, name)
+		raw := encodeLines(t, fa, "\ufeffERROR\n")
+		line, err := fa.Decode(raw)
+		if err != nil || string(line) != "ERROR\n" {
+			t.Errorf("%s: Decode = %q, %v", name, line, err)
+		}
+	}
+}
+
+type brokenDecoder struct{ transform.NopResetter }
+
+func (brokenDecoder) Transform(dst, src []byte, atEOF bool) (int, int, error) {
+	return 0, 0, errors.New("broken")
+}
+
+type brokenEncoding struct{}
+
+func (brokenEncoding) NewDecoder() *encoding.Decoder {
+	return &encoding.Decoder{Transformer: brokenDecoder{}}
+}
+
+func (brokenEncoding) NewEncoder() *encoding.Encoder {
+	return nil
+}
+
+func TestDecodeFailCounted(t *testing.T) {
+	defer func(saved *Config) { config = saved }(config)
+	log.SetOutput(ioutil.Discard)
+	config = &Config{Falcon: FalconConfig{Timestamp: TIMESTAMP_AT_START}}
+
+	fa := &FileAgent{Name: "test", Delimiter: "\n", Encoding: brokenEncoding{}, CodeUnit: 1}
+	fa.ProcessLine([]byte("ERROR one\n"))
+	fa.ProcessLine([]byte("ERROR two\n"))
+
+	var failed *FalconData
+	for _, point := range fa.Status(1000) {
+		if point.Metric == "agent.decode.failed" {
+			failed = point
+		}
+	}
+	if failed == nil || failed.Value != int64(2) || failed.Tags != "log=test" {
+		t.Fatalf("agent.decode.failed = %+v", failed)
+	}
+	if fa.DecodeFail != 0 {
+		t.Fatalf("decode failures are not reset")
+	}
+}
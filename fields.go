@@ -0,0 +1,206 @@
+/*
+* fields.go - the fields of log entry
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the functions to filter log entries by the fields
+* carried by the input, such as program/facility/severity of syslog,
+* and to split the windows of a task into series by the field tags
+ */
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// at most so many tag sets are kept for a task
+	MAX_SERIES = 1000
+
+	// a series without data for so many steps is removed
+	SERIE_IDLE_STEPS = 10
+
+	UNKNOWN_FIELD_VALUE = "unknown"
+)
+
+type FieldFilter map[string]*regexp.Regexp
+
+/*
+* NewFieldFilter - compile the field patterns of an item
+*
+* PARAMS:
+*   - match: field name and the pattern its value should match
+*
+* RETURNS:
+*   - FieldFilter, nil: if succeed, nil filter if nothing configured
+*   - nil, error: if fail
+ */
+func NewFieldFilter(match map[string]string) (FieldFilter, error) {
+	if len(match) == 0 {
+		return nil, nil
+	}
+
+	filter := make(FieldFilter)
+	for name, pattern := range match {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern of field %s compiling FAIL: %v", name, err)
+		}
+		filter[name] = re
+	}
+	return filter, nil
+}
+
+/*
+* Match - check whether the fields match all patterns
+*
+* RECEIVER: FieldFilter
+*
+* PARAMS:
+*   - fields: fields of log entry, nil for plain lines
+*
+* RETURNS:
+*   - true: if all patterns match, or no pattern configured
+*   - false: if not
+ */
+func (filter FieldFilter) Match(fields map[string]string) bool {
+	for name, re := range filter {
+		if !re.MatchString(fields[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+* Serie - find or create the series of task for the field tags
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   - fields: fields of log entry
+*   - ts: timestamp of log entry
+*
+* RETURNS:
+*   - *AgentTask: the task itself if no tag field configured
+*   - nil: if there are too many series
+ */
+func (task *AgentTask) Serie(fields map[string]string, ts int64) *AgentTask {
+	if len(task.TagFields) == 0 {
+		return task
+	}
+
+	tags := task.Tags
+	for _, name := range task.TagFields {
+		// the entry does not have the field at all
+		value, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if value == "" {
+			value = UNKNOWN_FIELD_VALUE
+		}
+		// ',' and '=' are the separators of falcon tags
+		value = strings.NewReplacer(",", "_", "=", "_").Replace(value)
+		if tags != "" {
+			tags += ","
+		}
+		tags += name + "=" + value
+	}
+
+	if serie, ok := task.Series[tags]; ok {
+		return serie
+	}
+
+	if len(task.Series) >= MAX_SERIES {
+		// counted in agent.series.dropped, logged once a status step
+		if task.Dropped == 0 {
+			log.Printf("%s has too many series, drop %s", task.Metric, tags)
+		}
+		task.Dropped += 1
+		return nil
+	}
+
+	serie := new(AgentTask)
+	*serie = *task
+	serie.Tags = tags
+	serie.TagFields = nil
+	serie.Dropped = 0
+	serie.Reset()
+	serie.Active = ts
+
+	if task.Series == nil {
+		task.Series = make(map[string]*AgentTask)
+	}
+	task.Series[tags] = serie
+
+	return serie
+}
+
+/*
+* EvictSeries - remove the series without data for SERIE_IDLE_STEPS steps
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   - now: current timestamp, or the watermark of log timestamps
+*
+* RETURNS:
+*   No return value
+ */
+func (task *AgentTask) EvictSeries(now int64) {
+	for tags, serie := range task.Series {
+		if now-serie.Active < SERIE_IDLE_STEPS*serie.Step || serie.LateCnt > 0 {
+			continue
+		}
+
+		// the data not reported yet keeps the series, empty windows do not
+		idle := true
+		for _, window := range serie.Windows {
+			if window.ValueCnt > 0 {
+				idle = false
+				break
+			}
+		}
+		if idle {
+			delete(task.Series, tags)
+		}
+	}
+}
+
+/*
+* AllSeries - the tasks holding windows
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - []*AgentTask: series ordered by tags, or the task itself
+ */
+func (task *AgentTask) AllSeries() []*AgentTask {
+	if len(task.TagFields) == 0 {
+		return []*AgentTask{task}
+	}
+
+	var keys []string
+	for tags := range task.Series {
+		keys = append(keys, tags)
+	}
+	sort.Strings(keys)
+
+	var series []*AgentTask
+	for _, tags := range keys {
+		series = append(series, task.Series[tags])
+	}
+	return series
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"testing"
+)
+
+func newSerieTask(t *testing.T) *AgentTask {
+	task, err := NewAgentTask(ItemConfig{Metric: "error", Pattern: "ERROR", Method: "count", Step: 60, CounterType: "GAUGE"})
+	if err != nil {
+		t.Fatalf("NewAgentTask: %v", err)
+	}
+	task.TagFields = []string{"pod"}
+	return task
+}
+
+func TestEvictSeries(t *testing.T) {
+	task := newSerieTask(t)
+
+	serie := task.Serie(map[string]string{"pod": "a"}, 1000)
+	serie.Locate(1000, 1000, 0).Count()
+
+	// the data not reported keeps the series however long it waits
+	task.EvictSeries(1000 + 100*60)
+	if len(task.Series) != 1 {
+		t.Fatalf("series with data is evicted")
+	}
+
+	// window 960~1019 is reported
+	serie.Expire(math.MaxInt64)
+	task.EvictSeries(1019 + SERIE_IDLE_STEPS*60 - 1)
+	if len(task.Series) != 1 {
+		t.Fatalf("series is evicted before %d idle steps", SERIE_IDLE_STEPS)
+	}
+
+	// zero-filled windows are empty, they do not keep the series
+	serie.Locate(1019+SERIE_IDLE_STEPS*60, 1019+SERIE_IDLE_STEPS*60, 0)
+	task.EvictSeries(1019 + SERIE_IDLE_STEPS*60)
+	if len(task.Series) != 0 {
+		t.Fatalf("idle series is not evicted")
+	}
+}
+
+func TestSeriesDropped(t *testing.T) {
+	task := newSerieTask(t)
+
+	for i := 0; i < MAX_SERIES; i++ {
+		if serie := task.Serie(map[string]string{"pod": fmt.Sprintf("p%d", i)}, 1000); serie == nil {
+			t.Fatalf("series #%d is dropped", i)
+		}
+	}
+	log.SetOutput(ioutil.Discard)
+	if serie := task.Serie(map[string]string{"pod": "new"}, 1000); serie != nil || task.Dropped != 1 {
+		t.Fatalf("series over MAX_SERIES is not dropped, dropped %d", task.Dropped)
+	}
+
+	// the dead series make room for the new ones
+	task.EvictSeries(1000 + SERIE_IDLE_STEPS*60)
+	if serie := task.Serie(map[string]string{"pod": "new"}, 2000); serie == nil {
+		t.Fatalf("series is dropped after the idle ones are evicted")
+	}
+}
+
+func TestStatusSeriesDropped(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	config = &Config{Falcon: FalconConfig{Timestamp: TIMESTAMP_AT_START}}
+
+	agent, err := NewFileAgent(LogConfig{
+		Name:  "pods",
+		Type:  LOG_TYPE_CONTAINER,
+		Path:  "/var/log/pods/*/*/*.log",
+		Items: []ItemConfig{{Metric: "error", Pattern: "ERROR", Method: "count", Step: 60, CounterType: "GAUGE"}},
+	})
+	if err != nil {
+		t.Fatalf("NewFileAgent: %v", err)
+	}
+	agent.Tasks[0].Dropped = 3
+
+	var dropped *FalconData
+	for _, point := range agent.Status(1000) {
+		if point.Metric == "agent.series.dropped" {
+			dropped = point
+		}
+	}
+	if dropped == nil || dropped.Value != int64(3) || dropped.Tags != "log=pods,metric=error" {
+		t.Fatalf("agent.series.dropped = %+v", dropped)
+	}
+	if agent.Tasks[0].Dropped != 0 {
+		t.Fatalf("dropped count is not reset")
+	}
+}
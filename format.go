@@ -0,0 +1,91 @@
+/*
+* format.go - the json and toml formats of configuration
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the functions to convert the configuration in json
+* or toml to yaml before it is unmarshaled, so that all the formats are
+* mapped to the same structures and validated by the same rules
+* the format is told by the extension of file, or set by --config-format
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	CONFIG_FORMAT_YAML = "yaml"
+	CONFIG_FORMAT_JSON = "json"
+	CONFIG_FORMAT_TOML = "toml"
+)
+
+// format of configuration file, by the extension if empty
+var configFormat string
+
+/*
+* ConfigFormat - the format of a configuration file
+*
+* PARAMS:
+*   - filename: path of configuration file
+*
+* RETURNS:
+*   - format of file
+ */
+func ConfigFormat(filename string) string {
+	if filename == configFile && configFormat != "" {
+		return configFormat
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return CONFIG_FORMAT_JSON
+	case ".toml":
+		return CONFIG_FORMAT_TOML
+	}
+	return CONFIG_FORMAT_YAML
+}
+
+/*
+* ConvertConfig - convert the content of configuration to yaml
+*
+* PARAMS:
+*   - buf: content of configuration
+*   - format: format of content
+*
+* RETURNS:
+*   - []byte, nil: content in yaml if succeed
+*   - nil, error: if fail
+ */
+func ConvertConfig(buf []byte, format string) ([]byte, error) {
+	var content interface{}
+
+	switch format {
+	case CONFIG_FORMAT_YAML:
+		return buf, nil
+	case CONFIG_FORMAT_JSON:
+		if err := json.Unmarshal(buf, &content); err != nil {
+			return nil, fmt.Errorf("json file unmarshal FAIL: %v", err)
+		}
+	case CONFIG_FORMAT_TOML:
+		var table map[string]interface{}
+		if err := toml.Unmarshal(buf, &table); err != nil {
+			return nil, fmt.Errorf("toml file unmarshal FAIL: %v", err)
+		}
+		content = table
+	default:
+		return nil, fmt.Errorf("format of configuration should be 'yaml'/'json'/'toml'")
+	}
+
+	return yaml.Marshal(content)
+}
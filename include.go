@@ -0,0 +1,173 @@
+/*
+* include.go - the log configurations included from a directory
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the functions to load the files matching the include
+* glob of configuration, each of them contributes its logs, a file with
+* errors is skipped, or keeps its last valid logs, without affecting the
+* other files, so that the logs of different applications are owned apart
+* the last valid logs are taken from the running configuration, loading a
+* candidate configuration never changes them
+ */
+
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+)
+
+type IncludeConfig struct {
+	Logs []LogConfig `yaml:"logs"`
+}
+
+type IncludeError struct {
+	File string
+	Err  error
+}
+
+/*
+* Error - the error message with the file name
+*
+* RECEIVER: *IncludeError
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - error message
+ */
+func (e *IncludeError) Error() string {
+	return fmt.Sprintf("%s: %v", e.File, e.Err)
+}
+
+/*
+* IncludeFiles - find the files matching the include glob
+*
+* PARAMS:
+*   - include: glob of files, relative to the directory of configuration file
+*
+* RETURNS:
+*   - []string: files in order, nil if include is empty
+*   - error: if the glob is malformed
+ */
+func IncludeFiles(include string) ([]string, error) {
+	if include == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(include) {
+		include = filepath.Join(filepath.Dir(configFile), include)
+	}
+	return filepath.Glob(include)
+}
+
+/*
+* LoadIncludes - append the logs of included files to configuration
+*
+* PARAMS:
+*   - cfg: configuration with the logs of main file
+*   - strict: unknown keys in included files are errors
+*
+* RETURNS:
+*   - []error: errors of included files, each is an *IncludeError
+ */
+func LoadIncludes(cfg *Config, strict bool) []error {
+	var errs []error
+
+	// the last valid logs of each included file
+	var included map[string][]LogConfig
+	if config != nil {
+		included = config.Included
+	}
+	cfg.Included = make(map[string][]LogConfig)
+
+	files, err := IncludeFiles(cfg.Include)
+	if err != nil {
+		return []error{fmt.Errorf("Include of configuration %s: %v", cfg.Include, err)}
+	}
+
+	names := make(map[string]string)
+	for _, one := range cfg.Logs {
+		names[one.Name] = configFile
+	}
+
+	for _, file := range files {
+		logs, fileErrs := LoadInclude(file, cfg.Defaults, strict)
+		for _, one := range logs {
+			if owner, ok := names[one.Name]; ok {
+				fileErrs = append(fileErrs, fmt.Errorf("Name of log %s is duplicated with %s", one.Name, owner))
+			}
+		}
+
+		if len(fileErrs) > 0 {
+			for _, err := range fileErrs {
+				errs = append(errs, &IncludeError{File: file, Err: err})
+			}
+			// the file is broken by the change, run its logs as before
+			logs = nil
+			for _, one := range included[file] {
+				if _, ok := names[one.Name]; !ok {
+					logs = append(logs, one)
+				}
+			}
+			if last, ok := included[file]; ok {
+				cfg.Included[file] = last
+			}
+		} else {
+			cfg.Included[file] = logs
+		}
+
+		for _, one := range logs {
+			names[one.Name] = file
+			cfg.Logs = append(cfg.Logs, one)
+		}
+	}
+
+	return errs
+}
+
+/*
+* LoadInclude - read and validate an included file
+*
+* PARAMS:
+*   - file: included file
+*   - defaults: defaults of configuration
+*   - strict: unknown keys are errors
+*
+* RETURNS:
+*   - []LogConfig: logs of file
+*   - []error: errors found, empty if the file is valid
+ */
+func LoadInclude(file string, defaults DefaultsConfig, strict bool) ([]LogConfig, []error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, []error{fmt.Errorf("configuration file reading FAIL: %v", err)}
+	}
+	if buf, err = ConvertConfig(buf, ConfigFormat(file)); err != nil {
+		return nil, []error{err}
+	}
+
+	var errs []error
+	inc := new(IncludeConfig)
+	if strict {
+		if err := yaml.UnmarshalStrict(buf, new(IncludeConfig)); err != nil {
+			errs = append(errs, fmt.Errorf("yaml file unmarshal FAIL: %v", err))
+		}
+	}
+	if err := yaml.Unmarshal(buf, inc); err != nil {
+		if len(errs) == 0 {
+			errs = append(errs, fmt.Errorf("yaml file unmarshal FAIL: %v", err))
+		}
+		return nil, errs
+	}
+
+	ApplyDefaults(inc.Logs, defaults)
+	errs = append(errs, ExpandLogs(inc.Logs)...)
+	return inc.Logs, append(errs, ValidateLogs(inc.Logs)...)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const includeMainConfig = `
+falcon:
+  url: http://127.0.0.1:1988/v1/push
+include: conf.d/*.yaml
+defaults:
+  counterType: GAUGE
+  step: 60
+  method: count
+logs:
+  - name: main
+    path: /var/log/main.log
+    items:
+      - metric: main.error
+        pattern: ERROR
+`
+
+const includeAppConfig = `
+logs:
+  - name: app
+    path: /var/log/app.log
+    items:
+      - metric: app.error
+        pattern: ERROR
+`
+
+func logNames(cfg *Config) []string {
+	var names []string
+	for _, one := range cfg.Logs {
+		names = append(names, one.Name)
+	}
+	return names
+}
+
+func TestLoadIncludesNoSideEffect(t *testing.T) {
+	defer useTempConfigFile(t)()
+	defer func(saved *Config) { config = saved }(config)
+	config = nil
+
+	dir := filepath.Join(filepath.Dir(configFile), "conf.d")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	app := filepath.Join(dir, "app.yaml")
+	if err := ioutil.WriteFile(app, []byte(includeAppConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	running := LoadConfigData([]byte(includeMainConfig))
+	if running == nil || !reflect.DeepEqual(logNames(running), []string{"main", "app"}) {
+		t.Fatalf("logs of running configuration = %v", running)
+	}
+	config = running
+	included := running.Included[app]
+
+	// the broken file runs its last valid logs
+	if err := ioutil.WriteFile(app, []byte("logs: [broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	candidate := LoadConfigData([]byte(includeMainConfig))
+	if candidate == nil || !reflect.DeepEqual(logNames(candidate), []string{"main", "app"}) {
+		t.Fatalf("logs with broken include = %v", candidate)
+	}
+
+	// a removed file is dropped from the candidate only
+	if err := os.Remove(app); err != nil {
+		t.Fatal(err)
+	}
+	candidate = LoadConfigData([]byte(includeMainConfig))
+	if candidate == nil || !reflect.DeepEqual(logNames(candidate), []string{"main"}) || len(candidate.Included) != 0 {
+		t.Fatalf("logs with removed include = %v", candidate)
+	}
+
+	if !reflect.DeepEqual(config.Included, map[string][]LogConfig{app: included}) {
+		t.Fatalf("included logs of running configuration are changed: %v", config.Included)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+* interpolate.go - the variables expanded in configuration
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the functions to expand ${VAR} in the endpoint of
+* falcon, the tags of items and the path of logs when configuration is
+* loaded, so that one configuration serves all the hosts
+* ${hostname} - hostname of this host
+* ${ip} - the first IPv4 address which is not loopback
+* ${VAR} - environment variable, an error if it is not set
+* ${VAR:-x} - environment variable, x if it is not set or empty
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+)
+
+var variableRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+/*
+* Interpolate - expand the variables in a string
+*
+* PARAMS:
+*   - s: string with variables
+*
+* RETURNS:
+*   - string, nil: the expanded string if succeed
+*   - string, error: if a variable is not set and has no default
+ */
+func Interpolate(s string) (string, error) {
+	var err error
+	expanded := variableRe.ReplaceAllStringFunc(s, func(variable string) string {
+		matches := variableRe.FindStringSubmatch(variable)
+		name, hasDefault, def := matches[1], matches[2] != "", matches[3]
+
+		value, ok := HostVariable(name)
+		if !ok {
+			value = os.Getenv(name)
+		}
+		if value != "" {
+			return value
+		}
+		if hasDefault {
+			return def
+		}
+		if err == nil {
+			err = fmt.Errorf("variable %s is not set", name)
+		}
+		return ""
+	})
+	return expanded, err
+}
+
+/*
+* HostVariable - the variables of host metadata
+*
+* PARAMS:
+*   - name: name of variable
+*
+* RETURNS:
+*   - value, true: if it is a host variable
+*   - "", false: if not
+ */
+func HostVariable(name string) (string, bool) {
+	switch name {
+	case "hostname":
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", true
+		}
+		return hostname, true
+	case "ip":
+		return LocalIP(), true
+	}
+	return "", false
+}
+
+/*
+* LocalIP - the first IPv4 address which is not loopback
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - ip address, empty if not found
+ */
+func LocalIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() {
+			continue
+		}
+		if ip := ipnet.IP.To4(); ip != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+/*
+* ExpandConfig - expand the variables of configuration
+*
+* PARAMS:
+*   - cfg: configuration
+*
+* RETURNS:
+*   - []error, empty if succeed
+ */
+func ExpandConfig(cfg *Config) []error {
+	var errs []error
+
+	endpoint, err := Interpolate(cfg.Falcon.Endpoint)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Endpoint of falcon: %v", err))
+	}
+	cfg.Falcon.Endpoint = endpoint
+
+	return append(errs, ExpandLogs(cfg.Logs)...)
+}
+
+/*
+* ExpandLogs - expand the variables of log configurations
+*
+* PARAMS:
+*   - logs: log configurations, expanded in place
+*
+* RETURNS:
+*   - []error, empty if succeed
+ */
+func ExpandLogs(logs []LogConfig) []error {
+	var errs []error
+
+	for i := range logs {
+		one := &logs[i]
+
+		path, err := Interpolate(one.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Path of log %s: %v", one.Name, err))
+		}
+		one.Path = path
+
+		for j := range one.Items {
+			item := &one.Items[j]
+			tags, err := Interpolate(item.Tags)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Tags of item %s: %v", item.Metric, err))
+			}
+			item.Tags = tags
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,394 @@
+/*
+* journal.go - systemd journal export format input
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the journal input which reads the journal export
+* format from a 'journalctl -o export -f' child process, or from a file
+* or named pipe, and matches the MESSAGE of each entry with the tasks of
+* agent, unit/priority/identifier/hostname are the fields of entry
+* the cursor of the last entry is saved, so that a restart resumes there
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	JOURNAL_COMMAND = "journalctl"
+
+	// the cursor is saved at most once in so many seconds
+	CURSOR_SAVE_INTERVAL = 5
+)
+
+type JournalEntry struct {
+	Message  []byte
+	Fields   map[string]string
+	Time     int64
+	Realtime int64
+	Cursor   string
+}
+
+type journalProcess struct {
+	cmd *exec.Cmd
+}
+
+/*
+* Close - stop the journalctl child process
+*
+* RECEIVER: journalProcess
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   nil
+ */
+func (p journalProcess) Close() error {
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+	return nil
+}
+
+/*
+* TailJournal - read journal entries in a loop
+*
+* PARAMS:
+*   - fa: file agent of journal input
+*   - finish: a channel to receiver stop signal
+*
+* RETURNS:
+*   No return value
+ */
+func TailJournal(fa *FileAgent, finish <-chan bool) {
+	log.Printf("agent for %s is launching...", fa.Name)
+
+	// create one second ticker
+	ticker := time.NewTicker(time.Second * 1)
+	defer ticker.Stop()
+
+	done := make(chan bool)
+	defer close(done)
+
+	fa.Cursor = fa.LoadCursor()
+	fa.ResetTasks()
+
+	entries, err := fa.JournalOpen(done)
+	if err != nil {
+		log.Printf("journal of %s open FAIL: %v", fa.Name, err)
+	}
+	saveTime := time.Now().Unix()
+
+JOURNAL:
+	for {
+		select {
+		case <-finish:
+			fa.StreamClose()
+			fa.SaveCursor()
+			break JOURNAL
+		case entry, ok := <-entries:
+			if ok {
+				fa.ProcessJournal(entry)
+				continue
+			}
+			log.Printf("journal of %s ends", fa.Name)
+			fa.StreamClose()
+			entries = nil
+		case <-ticker.C:
+			now := time.Now().Unix()
+			// restart journalctl, or read the file again when it grows
+			if fa.Stream == nil {
+				if entries, err = fa.JournalOpen(done); err != nil {
+					log.Printf("journal of %s open FAIL: %v", fa.Name, err)
+				}
+			}
+			if now-saveTime >= CURSOR_SAVE_INTERVAL {
+				fa.SaveCursor()
+				saveTime = now
+			}
+			fa.Timeup()
+		}
+	}
+
+	wg.Done()
+	log.Printf("agent for %s is exiting...", fa.Name)
+}
+
+/*
+* JournalOpen - start journalctl after the cursor, or open the export file
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - done: a channel closed when agent exits
+*
+* RETURNS:
+*   - <-chan *JournalEntry, nil: entries of journal if succeed
+*   - nil, error: if fail, or the file is not changed
+ */
+func (fa *FileAgent) JournalOpen(done <-chan bool) (<-chan *JournalEntry, error) {
+	var reader io.Reader
+
+	if fa.Filename == "" {
+		args := []string{"-o", "export", "-f"}
+		if fa.Cursor != "" {
+			args = append(args, "--after-cursor", fa.Cursor)
+		} else {
+			args = append(args, "-n", "0")
+		}
+		args = append(args, fa.JournalArgs...)
+
+		cmd := exec.Command(JOURNAL_COMMAND, args...)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		log.Printf("journal of %s: %s %s", fa.Name, JOURNAL_COMMAND, strings.Join(args, " "))
+
+		fa.Stream = journalProcess{cmd}
+		reader = stdout
+	} else {
+		fileinfo, err := os.Stat(fa.Filename)
+		if err != nil {
+			return nil, err
+		}
+
+		flag := os.O_RDONLY
+		if fileinfo.Mode()&os.ModeNamedPipe != 0 {
+			// the same as pipe input, opened for writing too
+			flag = os.O_RDWR | syscall.O_NONBLOCK
+		} else if fa.FileInfo != nil && fileinfo.Size() == fa.FileInfo.Size() && fileinfo.ModTime() == fa.FileInfo.ModTime() {
+			return nil, nil
+		}
+		fa.FileInfo = fileinfo
+
+		file, err := os.OpenFile(fa.Filename, flag, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fa.Stream = file
+		reader = file
+	}
+
+	entries := make(chan *JournalEntry, 16)
+	go ReadJournal(reader, entries, done)
+	return entries, nil
+}
+
+/*
+* ProcessJournal - match the message of a journal entry
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - entry: journal entry
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) ProcessJournal(entry *JournalEntry) {
+	fa.LastData = time.Now().Unix()
+
+	// the file is read from start again, skip the entries before the cursor
+	if fa.Filename != "" && fa.Cursor != "" {
+		if entry.Cursor == fa.Cursor || entry.Realtime < CursorTime(fa.Cursor) {
+			return
+		}
+	}
+
+	if entry.Cursor != "" {
+		fa.Cursor = entry.Cursor
+	}
+	fa.MatchEntry(entry.Message, entry.Fields, entry.Time)
+}
+
+/*
+* ReadJournal - parse the export format until the stream ends
+*
+* PARAMS:
+*   - r: stream in journal export format
+*   - entries: channel of entries, closed when the stream ends
+*   - done: a channel closed when agent exits
+*
+* RETURNS:
+*   No return value
+ */
+func ReadJournal(r io.Reader, entries chan<- *JournalEntry, done <-chan bool) {
+	defer close(entries)
+
+	reader := bufio.NewReaderSize(r, STREAM_CHUNK_SIZE)
+	fields := make(map[string][]byte)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if len(fields) > 0 && err == io.EOF {
+				select {
+				case entries <- NewJournalEntry(fields):
+				case <-done:
+				}
+			}
+			return
+		}
+
+		// an empty line ends the entry
+		if len(line) == 1 {
+			if len(fields) > 0 {
+				select {
+				case entries <- NewJournalEntry(fields):
+				case <-done:
+					return
+				}
+				fields = make(map[string][]byte)
+			}
+			continue
+		}
+
+		line = line[:len(line)-1]
+		if idx := bytes.IndexByte(line, '='); idx >= 0 {
+			fields[string(line[:idx])] = line[idx+1:]
+			continue
+		}
+
+		// binary field: name, 64-bit little endian size, data, newline
+		var size uint64
+		if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+			return
+		}
+		if size > MAX_LINE_SIZE {
+			log.Printf("journal field %s of %d bytes is too large", string(line), size)
+			return
+		}
+		data := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return
+		}
+		fields[string(line)] = data[:size]
+	}
+}
+
+/*
+* NewJournalEntry - map the journal fields to entry
+*
+* PARAMS:
+*   - fields: fields of journal entry
+*
+* RETURNS:
+*   - *JournalEntry
+ */
+func NewJournalEntry(fields map[string][]byte) *JournalEntry {
+	entry := new(JournalEntry)
+	entry.Message = fields["MESSAGE"]
+	entry.Cursor = string(fields["__CURSOR"])
+
+	if usec, err := strconv.ParseInt(string(fields["__REALTIME_TIMESTAMP"]), 10, 64); err == nil {
+		entry.Realtime = usec
+		entry.Time = usec / 1000000
+	}
+
+	entry.Fields = map[string]string{
+		"unit":       string(fields["_SYSTEMD_UNIT"]),
+		"identifier": string(fields["SYSLOG_IDENTIFIER"]),
+		"hostname":   string(fields["_HOSTNAME"]),
+	}
+	if priority, err := strconv.Atoi(string(fields["PRIORITY"])); err == nil && priority >= 0 && priority < len(syslogSeverities) {
+		entry.Fields["priority"] = syslogSeverities[priority]
+	} else {
+		entry.Fields["priority"] = ""
+	}
+
+	return entry
+}
+
+/*
+* CursorTime - the realtime timestamp in a journal cursor
+*
+* PARAMS:
+*   - cursor: journal cursor, such as 's=...;i=...;b=...;m=...;t=...;x=...'
+*
+* RETURNS:
+*   - realtime timestamp in microseconds, 0 if not found
+ */
+func CursorTime(cursor string) int64 {
+	for _, part := range strings.Split(cursor, ";") {
+		if strings.HasPrefix(part, "t=") {
+			if usec, err := strconv.ParseInt(part[2:], 16, 64); err == nil {
+				return usec
+			}
+		}
+	}
+	return 0
+}
+
+/*
+* LoadCursor - read the cursor saved by last run
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - cursor, empty if not saved
+ */
+func (fa *FileAgent) LoadCursor() string {
+	buf, err := ioutil.ReadFile(fa.CursorFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("cursor file %s reading FAIL: %v", fa.CursorFile, err)
+		}
+		return ""
+	}
+	cursor := strings.TrimSpace(string(buf))
+	fa.SavedCursor = cursor
+	return cursor
+}
+
+/*
+* SaveCursor - save the cursor of the last entry
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) SaveCursor() {
+	if fa.Cursor == "" || fa.Cursor == fa.SavedCursor {
+		return
+	}
+
+	// write a temporary file and rename it, a crash never leaves half a cursor
+	tmp := fa.CursorFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(fa.Cursor+"\n"), 0644); err != nil {
+		log.Printf("cursor file %s writing FAIL: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, fa.CursorFile); err != nil {
+		log.Printf("cursor file %s renaming FAIL: %v", fa.CursorFile, err)
+		return
+	}
+	fa.SavedCursor = fa.Cursor
+}
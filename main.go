@@ -16,10 +16,15 @@ package main
 
 import (
 	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -27,22 +32,79 @@ import (
 const (
 	CONFIG_CHECK_INTERVAL = 5
 
+	// seconds to report the windows in progress on exit
+	DEFAULT_FLUSH_TIMEOUT = 10
+
 	MAX_UNCHANGED_TIME = 5
 )
 
 type Record struct {
 	Name   string
+	Config LogConfig
 	Finish chan bool
+	Done   chan bool
 	Agent  *FileAgent
 }
 
 var records []*Record
 var wg sync.WaitGroup
 
+// read the file logs from stdin instead of their path
+var stdinMode bool
+
+// set by -ldflags "-X main.version=..." when building a release
+var version = "dev"
+
 // main
 func main() {
+	// subcommands
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		os.Exit(Backfill(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(Check(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		os.Exit(DryRun(os.Args[2:]))
+	}
+
+	flag.StringVar(&configFile, "config", configFile, "configuration file")
+	flag.StringVar(&configFile, "c", configFile, "configuration file (shorthand)")
+	flag.StringVar(&configFormat, "config-format", "", "format of configuration file, 'yaml'/'json'/'toml', by the extension if empty")
+	workDir := flag.String("workdir", "", "working directory, relative paths are resolved in it")
+	logFile := flag.String("log-file", "", "append the log to the file instead of stderr")
+	pidFile := flag.String("pid-file", "", "write the process id to the file")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flushTimeout := flag.Int("flush-timeout", DEFAULT_FLUSH_TIMEOUT, "seconds to report the windows in progress on exit")
+	configUrl := flag.String("config-url", "", "fetch the configuration from the url, --config is its local cache")
+	configInterval := flag.Int("config-interval", DEFAULT_REMOTE_INTERVAL, "seconds between two fetches of --config-url")
+	flag.BoolVar(&stdinMode, "stdin", false, "read the file logs from stdin, e.g. kubectl logs -f | log-agent --stdin")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Printf("log-agent %s\n", version)
+		os.Exit(0)
+	}
+
+	if *workDir != "" {
+		if err := os.Chdir(*workDir); err != nil {
+			fmt.Fprintf(os.Stderr, "working directory %s changing FAIL: %v\n", *workDir, err)
+			os.Exit(-1)
+		}
+	}
+
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "log file %s opening FAIL: %v\n", *logFile, err)
+			os.Exit(-1)
+		}
+		defer f.Close()
+		log.SetOutput(f)
+	}
+
 	sysCh := make(chan os.Signal, 1)
-	signal.Notify(sysCh, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	signal.Notify(sysCh, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
 	defer close(sysCh)
 
 	ticker := time.NewTicker(CONFIG_CHECK_INTERVAL * time.Second)
@@ -51,36 +113,86 @@ func main() {
 	// set log format
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	// the remote configuration is cached to the local file
+	var remote *RemoteSource
+	var remoteCh <-chan time.Time
+	if *configUrl != "" {
+		if *configInterval <= 0 {
+			log.Printf("config interval %d should be positive", *configInterval)
+			os.Exit(-1)
+		}
+
+		var err error
+		if remote, err = NewRemoteSource(*configUrl); err != nil {
+			log.Printf("config url %s FAIL: %v", *configUrl, err)
+			os.Exit(-1)
+		}
+		if _, err := remote.Fetch(); err != nil {
+			log.Printf("remote configuration fetching FAIL: %v, use the cached %s", err, configFile)
+		}
+
+		remoteTicker := time.NewTicker(time.Duration(*configInterval) * time.Second)
+		defer remoteTicker.Stop()
+		remoteCh = remoteTicker.C
+	}
+
 	// load configuration
 	config = LoadConfig()
 	if config == nil {
-		log.Printf("configuration loading FAIL, please check the config.yaml")
+		log.Printf("configuration loading FAIL, please check the %s", configFile)
 		os.Exit(-1)
 	}
 
 	// check configuration md5
-	md5sum, err := CheckConfigMD5()
+	md5sums, err := CheckConfigMD5()
 	if err != nil {
 		log.Printf("configuration checking FAIL")
 		os.Exit(-1)
 	}
-	configMD5Sum = md5sum
+	configMD5Sums = md5sums
+
+	if *pidFile != "" {
+		pid := []byte(fmt.Sprintf("%d\n", os.Getpid()))
+		if err := ioutil.WriteFile(*pidFile, pid, 0644); err != nil {
+			log.Printf("pid file %s writing FAIL: %v", *pidFile, err)
+			os.Exit(-1)
+		}
+		defer os.Remove(*pidFile)
+	}
 
 	StartAgent()
 
 MAIN:
 	for {
 		select {
-		case <-sysCh:
-			log.Printf("system signal: %v", sysCh)
-			StopAgent()
+		case sig := <-sysCh:
+			log.Printf("system signal: %v", sig)
+			switch sig {
+			case syscall.SIGHUP:
+				if md5sums, err := CheckConfigMD5(); err == nil {
+					ReloadConfig(md5sums)
+				}
+			case syscall.SIGUSR1:
+				DumpAgent()
+			default:
+				FlushAgent(time.Duration(*flushTimeout) * time.Second)
+				break MAIN
+			}
+		case <-stdinHub.Done:
+			log.Printf("stdin is finished")
+			FlushAgent(time.Duration(*flushTimeout) * time.Second)
 			break MAIN
 		case <-ticker.C:
 			RecheckConfig()
+		case <-remoteCh:
+			if changed, err := remote.Fetch(); err != nil {
+				log.Printf("remote configuration fetching FAIL: %v", err)
+			} else if changed {
+				RecheckConfig()
+			}
 		}
 	}
 
-	wg.Wait()
 	log.Printf("log-agent exit...")
 }
 
@@ -95,63 +207,16 @@ MAIN:
  */
 func StartAgent() {
 	for _, one := range config.Logs {
-
-		var tasks []*AgentTask
-		for _, item := range one.Items {
-			task := new(AgentTask)
-
-			task.Metric = item.Metric
-			task.Tags = item.Tags
-			task.CounterType = item.CounterType
-			task.Step = item.Step
-			task.Pattern = item.Pattern
-			task.Reversed = item.Reversed
-			task.Threshold = item.Threshold
-			task.Method = item.Method
-			task.TsStart = 0
-			task.TsEnd = 0
-			task.TsUpdate = 0
-			task.ValueCnt = 0
-			task.ValueMax = 0
-			task.ValueMin = 1 << 32
-			task.ValueSum = 0
-
-			tasks = append(tasks, task)
+		record, err := NewRecord(one)
+		if err != nil {
+			log.Printf("agent of %s creating FAIL: %v", one.Name, err)
+			continue
 		}
-
-		log.Printf("tasks: %v", tasks)
-
-		agent := new(FileAgent)
-		agent.Filename = one.Path
-		agent.File = nil
-		agent.FileInfo = nil
-		agent.LastOffset = 0
-		agent.UnchangeTime = 0
-		agent.Delimiter = one.Delimiter
-		agent.TsEnabled = one.TsEnabled
-		agent.TsPattern = one.TsPattern
-		agent.InotifyEnabled = one.InotifyEnabled
-		agent.Tasks = tasks
-
-		name := one.Name
-		ch := make(chan bool, 1)
-
-		record := new(Record)
-		record.Name = name
-		record.Finish = ch
-		record.Agent = agent
-
 		records = append(records, record)
 	}
 
 	for _, record := range records {
-		wg.Add(1)
-		log.Printf("wg: %v", wg)
-		if record.Agent.InotifyEnabled {
-			go TailWithInotify(record.Agent, record.Finish)
-		} else {
-			go TailWithCheck(record.Agent, record.Finish)
-		}
+		record.Start()
 	}
 }
 
@@ -172,6 +237,136 @@ func StopAgent() {
 	records = []*Record{}
 }
 
+/*
+* FlushAgent - stop the agents and report the windows in progress
+*
+* PARAMS:
+*   - timeout: the agents not flushed in time are given up
+*
+* RETURNS:
+*   No return value
+ */
+func FlushAgent(timeout time.Duration) {
+	stopping := records
+	StopAgent()
+
+	flushed := make(chan bool)
+	go func() {
+		for _, record := range stopping {
+			<-record.Done
+			record.Agent.Expire(math.MaxInt64)
+		}
+		wg.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		log.Printf("all agents are flushed")
+	case <-time.After(timeout):
+		log.Printf("agents flushing is not finished in %v, give up", timeout)
+	}
+}
+
+/*
+* DumpAgent - ask the agents to dump their state to the log
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func DumpAgent() {
+	log.Printf("config %s: %d logs", configFile, len(records))
+	for _, record := range records {
+		// dumped in the next Timeup of agent
+		atomic.StoreInt32(&record.Agent.DumpRequested, 1)
+	}
+}
+
+/*
+* NewRecord - generate the record of a log
+*
+* PARAMS:
+*   - one: log configuration
+*
+* RETURNS:
+*   - *Record, nil: if succeed
+*   - nil, error: if fail
+ */
+func NewRecord(one LogConfig) (*Record, error) {
+	agent, err := NewFileAgent(one)
+	if err != nil {
+		return nil, err
+	}
+
+	// ad-hoc use, all file logs read the lines piped in
+	if stdinMode && agent.Type == LOG_TYPE_FILE {
+		agent.Type = LOG_TYPE_PIPE
+		agent.Filename = STDIN_PATH
+	}
+
+	record := new(Record)
+	record.Name = one.Name
+	record.Config = one
+	record.Finish = make(chan bool, 1)
+	record.Done = make(chan bool)
+	record.Agent = agent
+
+	return record, nil
+}
+
+/*
+* Start - run the agent of record in a goroutine
+*
+* RECEIVER: *Record
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (record *Record) Start() {
+	wg.Add(1)
+	log.Printf("wg: %v", wg)
+
+	go func() {
+		defer close(record.Done)
+		if record.Agent.Type == LOG_TYPE_SYSLOG {
+			ListenSyslog(record.Agent, record.Finish)
+		} else if record.Agent.Type == LOG_TYPE_PIPE {
+			TailStream(record.Agent, record.Finish)
+		} else if record.Agent.Type == LOG_TYPE_CONTAINER {
+			TailContainers(record.Agent, record.Finish)
+		} else if record.Agent.Type == LOG_TYPE_JOURNAL {
+			TailJournal(record.Agent, record.Finish)
+		} else if record.Agent.InotifyEnabled {
+			TailWithInotify(record.Agent, record.Finish)
+		} else {
+			TailWithCheck(record.Agent, record.Finish)
+		}
+	}()
+}
+
+/*
+* Stop - stop the agent of record and wait until it exits
+*
+* RECEIVER: *Record
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (record *Record) Stop() {
+	record.Finish <- true
+	close(record.Finish)
+	<-record.Done
+}
+
 /*
 * RecheckConfig - check md5sum and reload the configuration file
 *
@@ -182,23 +377,51 @@ func StopAgent() {
 *   No return value
  */
 func RecheckConfig() {
-	newMD5Sum, err := CheckConfigMD5()
+	newMD5Sums, err := CheckConfigMD5()
 	if err != nil {
 		log.Printf("configuration checking FAIL")
 		return
 	}
 
-	if !bytes.Equal(configMD5Sum, newMD5Sum) {
-		log.Printf("old %x ----- new %x", configMD5Sum, newMD5Sum)
-		cfg := LoadConfig()
-		if cfg == nil {
-			log.Printf("configuration loading FAIL, please check the config.yaml!")
-			return
+	changed := false
+	for file, newMD5Sum := range newMD5Sums {
+		if md5sum, ok := configMD5Sums[file]; !ok {
+			log.Printf("%s is added", file)
+			changed = true
+		} else if !bytes.Equal(md5sum, newMD5Sum) {
+			log.Printf("%s is changed, old %x ----- new %x", file, md5sum, newMD5Sum)
+			changed = true
+		}
+	}
+	for file := range configMD5Sums {
+		if _, ok := newMD5Sums[file]; !ok {
+			log.Printf("%s is removed", file)
+			changed = true
 		}
-		config = cfg
-		configMD5Sum = newMD5Sum
+	}
 
-		StopAgent()
-		StartAgent()
+	if changed {
+		ReloadConfig(newMD5Sums)
+	}
+}
+
+/*
+* ReloadConfig - load the configuration file and apply it to the agents
+*
+* PARAMS:
+*   - md5sums: md5sum of the configuration file and included files
+*
+* RETURNS:
+*   No return value
+ */
+func ReloadConfig(md5sums map[string][]byte) {
+	cfg := LoadConfig()
+	if cfg == nil {
+		log.Printf("configuration loading FAIL, please check the %s!", configFile)
+		return
 	}
+	SetConfig(cfg)
+	configMD5Sums = md5sums
+
+	ReloadAgent()
 }
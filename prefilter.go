@@ -0,0 +1,327 @@
+/*
+* prefilter.go - literal prefilter of the agent tasks
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the literal prefilter which extracts the literals
+* required by each task pattern and scans a line once with an Aho-Corasick
+* automaton, so that most lines are rejected without running any regex
+ */
+
+package main
+
+import (
+	"regexp/syntax"
+)
+
+type Prefilter struct {
+	Delta      [][256]int32
+	Output     [][]int
+	Always     []int
+	Candidates []bool
+}
+
+/*
+* NewPrefilter - build the prefilter for tasks
+*
+* PARAMS:
+*   - tasks: tasks of file agent
+*
+* RETURNS:
+*   - *Prefilter
+ */
+func NewPrefilter(tasks []*AgentTask) *Prefilter {
+	pf := new(Prefilter)
+	pf.Candidates = make([]bool, len(tasks))
+
+	// root node
+	pf.Delta = append(pf.Delta, [256]int32{})
+	pf.Output = append(pf.Output, nil)
+
+	for idx, task := range tasks {
+		literals := TaskLiterals(task)
+		if literals == nil {
+			pf.Always = append(pf.Always, idx)
+			continue
+		}
+		for _, literal := range literals {
+			pf.insert(literal, idx)
+		}
+	}
+
+	pf.build()
+	return pf
+}
+
+/*
+* insert - add a literal of task to the trie
+*
+* RECEIVER: *Prefilter
+*
+* PARAMS:
+*   - literal: literal required by task
+*   - idx: index of task
+*
+* RETURNS:
+*   No return value
+ */
+func (pf *Prefilter) insert(literal string, idx int) {
+	state := int32(0)
+	for i := 0; i < len(literal); i++ {
+		b := literal[i]
+		if pf.Delta[state][b] == 0 {
+			pf.Delta = append(pf.Delta, [256]int32{})
+			pf.Output = append(pf.Output, nil)
+			pf.Delta[state][b] = int32(len(pf.Delta) - 1)
+		}
+		state = pf.Delta[state][b]
+	}
+	pf.Output[state] = append(pf.Output[state], idx)
+}
+
+/*
+* build - turn the trie into a full transition table with failure links
+*
+* RECEIVER: *Prefilter
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (pf *Prefilter) build() {
+	fail := make([]int32, len(pf.Delta))
+	var queue []int32
+
+	for b := 0; b < 256; b++ {
+		if next := pf.Delta[0][b]; next != 0 {
+			fail[next] = 0
+			queue = append(queue, next)
+		}
+	}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		pf.Output[state] = append(pf.Output[state], pf.Output[fail[state]]...)
+
+		for b := 0; b < 256; b++ {
+			next := pf.Delta[state][b]
+			if next == 0 {
+				pf.Delta[state][b] = pf.Delta[fail[state]][b]
+				continue
+			}
+			fail[next] = pf.Delta[fail[state]][b]
+			queue = append(queue, next)
+		}
+	}
+
+	// an index may be reached through several literals, keep it once per state
+	for state, output := range pf.Output {
+		pf.Output[state] = uniqueInts(output)
+	}
+}
+
+/*
+* Scan - find the tasks which may match the line
+*
+* RECEIVER: *Prefilter
+*
+* PARAMS:
+*   - line: one line of log
+*
+* RETURNS:
+*   - []bool: candidate flag of each task, valid until next scan
+*   - bool: true if there is any candidate
+ */
+func (pf *Prefilter) Scan(line []byte) ([]bool, bool) {
+	for idx := range pf.Candidates {
+		pf.Candidates[idx] = false
+	}
+
+	found := false
+	for _, idx := range pf.Always {
+		pf.Candidates[idx] = true
+		found = true
+	}
+
+	state := int32(0)
+	for _, b := range line {
+		state = pf.Delta[state][b]
+		for _, idx := range pf.Output[state] {
+			pf.Candidates[idx] = true
+			found = true
+		}
+	}
+
+	return pf.Candidates, found
+}
+
+/*
+* TaskLiterals - extract the literals one of which a matched line must contain
+*
+* PARAMS:
+*   - task: agent task
+*
+* RETURNS:
+*   - []string: required literals
+*   - nil: if the task can not be prefiltered
+ */
+func TaskLiterals(task *AgentTask) []string {
+	var best []string
+
+	if task.Re != nil && !(task.Method == "count" && task.Reversed) {
+		best = patternLiterals(task.Re.String())
+	}
+
+	if task.Filter != nil && len(task.Filter.Include) > 0 {
+		var literals []string
+		for _, re := range task.Filter.Include {
+			one := patternLiterals(re.String())
+			if task.Filter.IncludeAll {
+				// every include pattern is required, any one of them is enough
+				if one != nil && betterLiterals(one, literals) {
+					literals = one
+				}
+				continue
+			}
+			if one == nil {
+				literals = nil
+				break
+			}
+			literals = append(literals, one...)
+		}
+		if literals != nil && betterLiterals(literals, best) {
+			best = literals
+		}
+	}
+
+	return best
+}
+
+/*
+* patternLiterals - extract the required literals of a pattern
+*
+* PARAMS:
+*   - pattern: regular expression
+*
+* RETURNS:
+*   - []string: required literals
+*   - nil: if no literal is required
+ */
+func patternLiterals(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return requiredLiterals(re)
+}
+
+/*
+* requiredLiterals - walk the syntax tree to find the required literals
+*
+* PARAMS:
+*   - re: syntax tree of regular expression
+*
+* RETURNS:
+*   - []string: required literals
+*   - nil: if no literal is required
+ */
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil
+		}
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var best []string
+		for _, sub := range re.Sub {
+			literals := requiredLiterals(sub)
+			if literals != nil && betterLiterals(literals, best) {
+				best = literals
+			}
+		}
+		return best
+	case syntax.OpAlternate:
+		var all []string
+		for _, sub := range re.Sub {
+			literals := requiredLiterals(sub)
+			if literals == nil {
+				return nil
+			}
+			all = append(all, literals...)
+		}
+		return all
+	}
+	return nil
+}
+
+/*
+* betterLiterals - compare two literal sets by the shortest literal
+*
+* PARAMS:
+*   - a: literal set
+*   - b: literal set
+*
+* RETURNS:
+*   - true: if a is more selective than b
+*   - false: if not
+ */
+func betterLiterals(a []string, b []string) bool {
+	if b == nil {
+		return true
+	}
+	return minLength(a) > minLength(b)
+}
+
+/*
+* minLength - length of the shortest literal
+*
+* PARAMS:
+*   - literals: literal set
+*
+* RETURNS:
+*   - int: the shortest length
+ */
+func minLength(literals []string) int {
+	min := -1
+	for _, literal := range literals {
+		if min < 0 || len(literal) < min {
+			min = len(literal)
+		}
+	}
+	return min
+}
+
+/*
+* uniqueInts - remove duplicated elements
+*
+* PARAMS:
+*   - list: int list
+*
+* RETURNS:
+*   - []int: list without duplicated elements
+ */
+func uniqueInts(list []int) []int {
+	var unique []int
+	seen := make(map[int]bool)
+	for _, one := range list {
+		if !seen[one] {
+			seen[one] = true
+			unique = append(unique, one)
+		}
+	}
+	return unique
+}
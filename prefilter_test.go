@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestTasks(t testing.TB, items []ItemConfig) []*AgentTask {
+	var tasks []*AgentTask
+	for _, item := range items {
+		if item.Method == "" {
+			item.Method = "count"
+		}
+		task, err := NewAgentTask(item)
+		if err != nil {
+			t.Fatalf("NewAgentTask(%s): %v", item.Pattern, err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+func candidateList(candidates []bool) []int {
+	var list []int
+	for idx, candidate := range candidates {
+		if candidate {
+			list = append(list, idx)
+		}
+	}
+	return list
+}
+
+func TestPatternLiterals(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		literals []string
+	}{
+		{`ERROR`, []string{"ERROR"}},
+		{`cost=(\d+)ms`, []string{"cost="}},
+		{`(timeout|refused) to \S+`, []string{"timeout", "refused"}},
+		{`(foo)?bar`, []string{"bar"}},
+		{`(abc)+`, []string{"abc"}},
+		{`(abc){2,3}`, []string{"abc"}},
+		{`\d+`, nil},
+		{`(?i)error`, nil},
+		{`x*`, nil},
+		{`foo|\d+`, nil},
+	}
+
+	for _, c := range cases {
+		literals := patternLiterals(c.pattern)
+		if !reflect.DeepEqual(literals, c.literals) {
+			t.Errorf("patternLiterals(%q) = %q, want %q", c.pattern, literals, c.literals)
+		}
+	}
+}
+
+func TestPrefilterScan(t *testing.T) {
+	// the literals overlap, so the failure links are needed
+	tasks := newTestTasks(t, []ItemConfig{
+		{Pattern: "he"},
+		{Pattern: "she"},
+		{Pattern: "his"},
+		{Pattern: "hers"},
+	})
+	pf := NewPrefilter(tasks)
+
+	cases := []struct {
+		line       string
+		candidates []int
+	}{
+		{"ushers", []int{0, 1, 3}},
+		{"this", []int{2}},
+		{"ahishers", []int{0, 1, 2, 3}},
+		{"hhhhe", []int{0}},
+		{"", nil},
+		{"nothing", nil},
+	}
+
+	for _, c := range cases {
+		candidates, found := pf.Scan([]byte(c.line))
+		list := candidateList(candidates)
+		if !reflect.DeepEqual(list, c.candidates) || found != (c.candidates != nil) {
+			t.Errorf("Scan(%q) = %v %v, want %v", c.line, list, found, c.candidates)
+		}
+	}
+}
+
+func TestPrefilterAlways(t *testing.T) {
+	tasks := newTestTasks(t, []ItemConfig{
+		{Pattern: "ERROR"},
+		{Pattern: `\d+`},
+		{Pattern: "(?i)warn"},
+		{Pattern: "DEBUG", Reversed: true},
+	})
+	pf := NewPrefilter(tasks)
+
+	if !reflect.DeepEqual(pf.Always, []int{1, 2, 3}) {
+		t.Fatalf("Always = %v, want [1 2 3]", pf.Always)
+	}
+	candidates, found := pf.Scan([]byte("INFO ok"))
+	if list := candidateList(candidates); !found || !reflect.DeepEqual(list, []int{1, 2, 3}) {
+		t.Errorf("Scan = %v %v, want [1 2 3]", list, found)
+	}
+	candidates, _ = pf.Scan([]byte("ERROR failed"))
+	if list := candidateList(candidates); !reflect.DeepEqual(list, []int{0, 1, 2, 3}) {
+		t.Errorf("Scan = %v, want [0 1 2 3]", list)
+	}
+}
+
+func TestPrefilterInclude(t *testing.T) {
+	tasks := newTestTasks(t, []ItemConfig{
+		// any include pattern, each of them is required
+		{Include: []string{"GET", "POST"}},
+		// all include patterns, the longest literal is enough
+		{Include: []string{"GET", "/api/"}, IncludeMode: "all"},
+	})
+	pf := NewPrefilter(tasks)
+
+	cases := []struct {
+		line       string
+		candidates []int
+	}{
+		{"POST /login", []int{0}},
+		{"GET /api/users", []int{0, 1}},
+		{"PUT /api/users", []int{1}},
+		{"DELETE /", nil},
+	}
+
+	for _, c := range cases {
+		candidates, _ := pf.Scan([]byte(c.line))
+		if list := candidateList(candidates); !reflect.DeepEqual(list, c.candidates) {
+			t.Errorf("Scan(%q) = %v, want %v", c.line, list, c.candidates)
+		}
+	}
+}
+
+// a line matched by the pattern of a task is never rejected by the prefilter
+func TestPrefilterNoFalseNegative(t *testing.T) {
+	tasks := newTestTasks(t, []ItemConfig{
+		{Pattern: "ERROR"},
+		{Pattern: `cost=(\d+)ms`, Method: "statistic"},
+		{Pattern: `(timeout|refused) to \S+`},
+		{Pattern: `status=5\d\d`},
+		{Pattern: `(foo)?bar`},
+	})
+	pf := NewPrefilter(tasks)
+
+	for _, line := range benchmarkLines(1000) {
+		candidates, _ := pf.Scan(line)
+		for idx, task := range tasks {
+			if task.Re.Match(line) && !candidates[idx] {
+				t.Errorf("line %q matches %s but is rejected", line, task.Pattern)
+			}
+		}
+	}
+}
+
+var benchmarkItems = []ItemConfig{
+	{Metric: "error", Pattern: "ERROR", Method: "count"},
+	{Metric: "timeout", Pattern: `(timeout|refused) to \S+`, Method: "count"},
+	{Metric: "status5xx", Pattern: `status=5\d\d`, Method: "count"},
+	{Metric: "cost", Pattern: `cost=(\d+)ms`, Method: "statistic"},
+	{Metric: "slow", Pattern: `slow query (\d+)`, Method: "Tcount", Threshold: 100},
+}
+
+// one line in 100 is matched by some item
+func benchmarkLines(n int) [][]byte {
+	var lines [][]byte
+	for i := 0; i < n; i++ {
+		var line string
+		switch {
+		case i%500 == 0:
+			line = fmt.Sprintf("2026-10-19 10:00:00 ERROR request %d failed: timeout to db01", i)
+		case i%300 == 0:
+			line = fmt.Sprintf("2026-10-19 10:00:00 WARN slow query %d on orders", i%1000)
+		case i%200 == 0:
+			line = fmt.Sprintf("2026-10-19 10:00:00 INFO GET /api/items/%d status=503 cost=%dms", i, i%700)
+		default:
+			line = fmt.Sprintf("2026-10-19 10:00:00 INFO GET /api/items/%d status=200 user=u%d bytes=%d", i, i%97, i*13)
+		}
+		lines = append(lines, []byte(line))
+	}
+	return lines
+}
+
+func newBenchmarkAgent(b *testing.B) *FileAgent {
+	log.SetOutput(ioutil.Discard)
+	config = &Config{Falcon: FalconConfig{Timestamp: TIMESTAMP_AT_START}}
+
+	var items []ItemConfig
+	for _, item := range benchmarkItems {
+		item.Step = 60
+		item.CounterType = "GAUGE"
+		items = append(items, item)
+	}
+	agent, err := NewFileAgent(LogConfig{Name: "bench", Path: "bench.log", Items: items})
+	if err != nil {
+		b.Fatalf("NewFileAgent: %v", err)
+	}
+	agent.Sink = func(data []*FalconData) {}
+	agent.TsUpdate = time.Now().Unix()
+	return agent
+}
+
+func benchmarkMatch(b *testing.B, agent *FileAgent) {
+	lines := benchmarkLines(10000)
+	var size int64
+	for _, line := range lines {
+		size += int64(len(line))
+	}
+
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			agent.MatchLine(line)
+		}
+	}
+}
+
+// every task runs its regex on every line, as before the prefilter
+func BenchmarkMatchRegexOnly(b *testing.B) {
+	agent := newBenchmarkAgent(b)
+	pf := NewPrefilter(nil)
+	pf.Candidates = make([]bool, len(agent.Tasks))
+	for idx := range agent.Tasks {
+		pf.Always = append(pf.Always, idx)
+	}
+	agent.Prefilter = pf
+
+	benchmarkMatch(b, agent)
+}
+
+func BenchmarkMatchPrefilter(b *testing.B) {
+	benchmarkMatch(b, newBenchmarkAgent(b))
+}
+
+func BenchmarkPrefilterScan(b *testing.B) {
+	agent := newBenchmarkAgent(b)
+	lines := benchmarkLines(10000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			agent.Prefilter.Scan(line)
+		}
+	}
+}
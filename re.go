@@ -5,20 +5,26 @@
 * --------------------
 * 2017/8/18, by Ye Zhiqin, create
 * 2018/1/3, by Ye Zhiqin, modify
+* 2026/10/19, modify
 *
 * DESCRIPTION
-* This file contains three functions related to regular expression matching
+* This file contains the functions related to regular expression matching
 * MatchTs - match and extract timestamp in log
 * MatchKeyword - match the keyword in log
 * MatchCost - match and extract cost value in log
+* LineFilter - include/exclude pattern lists checked before matching
+* ValueExtractor - capture groups and list elements combined into the cost
  */
 
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -27,20 +33,14 @@ import (
 *
 * PARAMS:
 *   - line: one line of log
-*   - pattern: regular expression
+*   - re: compiled regular expression
 *
 * RETURNS:
 *   - true, timestamp, nil: if match
 *   - false, timestamp, nil: if not match
 *   - false, timestamp, error: if fail
  */
-func MatchTs(line []byte, pattern string) (bool, time.Time, error) {
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		log.Printf("re compiling FAIL: %v", err)
-		return false, time.Now(), err
-	}
-
+func MatchTs(line []byte, re *regexp.Regexp) (bool, time.Time, error) {
 	matches := re.FindSubmatch(line)
 
 	if matches == nil {
@@ -68,26 +68,19 @@ func MatchTs(line []byte, pattern string) (bool, time.Time, error) {
 *
 * PARAMS:
 *   - line: one line of log
-*   - pattern: regular expression
+*   - re: compiled regular expression
 *   - reversed: reverse the match result
 *
 * RETURNS:
-*   - true, nil: if match
-*   - false, nil: if not match
-*   - false, error: if fail
+*   - true: if match
+*   - false: if not match
  */
-func MatchKeyword(line []byte, pattern string, reversed bool) (bool, error) {
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		log.Printf("re compiling FAIL: %v", err)
-		return false, err
-	}
-
+func MatchKeyword(line []byte, re *regexp.Regexp, reversed bool) bool {
 	isMatch := re.Match(line)
 	if reversed {
-		return !isMatch, nil
+		return !isMatch
 	} else {
-		return isMatch, nil
+		return isMatch
 	}
 }
 
@@ -96,27 +89,22 @@ func MatchKeyword(line []byte, pattern string, reversed bool) (bool, error) {
 *
 * PARAMS:
 *   - line: one line of log
-*   - pattern: regular expression
+*   - re: compiled regular expression
+*   - extractor: value extractor
 *
 * RETURNS:
 *   - true, value, nil: if match
 *   - false, 0, nil: if not match
 *   - false, 0, error: if fail
  */
-func MatchCost(line []byte, pattern string) (bool, float64, error) {
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		log.Printf("re compiling FAIL: %v", err)
-		return false, 0, err
-	}
-
+func MatchCost(line []byte, re *regexp.Regexp, extractor *ValueExtractor) (bool, float64, error) {
 	matches := re.FindSubmatch(line)
 
 	if matches == nil {
 		return false, 0, nil
 	}
 
-	cost, err := strconv.ParseFloat(string(matches[1]), 64)
+	cost, err := extractor.Value(matches)
 	if err != nil {
 		log.Printf("cost data string converting FAIL: %v", err)
 		return true, 0, err
@@ -124,3 +112,276 @@ func MatchCost(line []byte, pattern string) (bool, float64, error) {
 
 	return true, cost, nil
 }
+
+const (
+	FILTER_MODE_ANY = "any"
+	FILTER_MODE_ALL = "all"
+)
+
+type LineFilter struct {
+	Include    []*regexp.Regexp
+	IncludeAll bool
+	Exclude    []*regexp.Regexp
+	ExcludeAll bool
+}
+
+/*
+* NewLineFilter - compile the include/exclude pattern lists
+*
+* PARAMS:
+*   - include: patterns a line should contain
+*   - includeMode: 'any' or 'all' of the include patterns
+*   - exclude: patterns a line should not contain
+*   - excludeMode: 'any' or 'all' of the exclude patterns
+*
+* RETURNS:
+*   - *LineFilter, nil: if succeed
+*   - nil, error: if fail
+ */
+func NewLineFilter(include []string, includeMode string, exclude []string, excludeMode string) (*LineFilter, error) {
+	filter := new(LineFilter)
+
+	switch includeMode {
+	case "", FILTER_MODE_ANY:
+		filter.IncludeAll = false
+	case FILTER_MODE_ALL:
+		filter.IncludeAll = true
+	default:
+		return nil, fmt.Errorf("IncludeMode of item should be 'any'/'all'")
+	}
+
+	switch excludeMode {
+	case "", FILTER_MODE_ANY:
+		filter.ExcludeAll = false
+	case FILTER_MODE_ALL:
+		filter.ExcludeAll = true
+	default:
+		return nil, fmt.Errorf("ExcludeMode of item should be 'any'/'all'")
+	}
+
+	for _, pattern := range include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include pattern %s compiling FAIL: %v", pattern, err)
+		}
+		filter.Include = append(filter.Include, re)
+	}
+
+	for _, pattern := range exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("exclude pattern %s compiling FAIL: %v", pattern, err)
+		}
+		filter.Exclude = append(filter.Exclude, re)
+	}
+
+	return filter, nil
+}
+
+/*
+* Match - check the line against include/exclude pattern lists
+*
+* RECEIVER: *LineFilter
+*
+* PARAMS:
+*   - line: one line of log
+*
+* RETURNS:
+*   - true: if the line is included and not excluded
+*   - false: if not
+ */
+func (filter *LineFilter) Match(line []byte) bool {
+	if filter == nil {
+		return true
+	}
+
+	if len(filter.Include) > 0 && !matchList(line, filter.Include, filter.IncludeAll) {
+		return false
+	}
+
+	if len(filter.Exclude) > 0 && matchList(line, filter.Exclude, filter.ExcludeAll) {
+		return false
+	}
+
+	return true
+}
+
+/*
+* matchList - match the line against a list of patterns
+*
+* PARAMS:
+*   - line: one line of log
+*   - list: compiled patterns
+*   - all: all patterns should match if true, any pattern if false
+*
+* RETURNS:
+*   - true: if match
+*   - false: if not match
+ */
+func matchList(line []byte, list []*regexp.Regexp, all bool) bool {
+	for _, re := range list {
+		isMatch := re.Match(line)
+		if all && !isMatch {
+			return false
+		}
+		if !all && isMatch {
+			return true
+		}
+	}
+	return all
+}
+
+const (
+	COMBINE_SUM   = "sum"
+	COMBINE_MAX   = "max"
+	COMBINE_MIN   = "min"
+	COMBINE_AVG   = "avg"
+	COMBINE_FIRST = "first"
+	COMBINE_LAST  = "last"
+)
+
+type ValueExtractor struct {
+	Groups    []int
+	Separator string
+	Combine   string
+	Transform *Transform
+}
+
+/*
+* NewValueExtractor - resolve the capture groups supplying the cost value
+*
+* PARAMS:
+*   - re: compiled regular expression of item
+*   - item: item configuration
+*
+* RETURNS:
+*   - *ValueExtractor, nil: if succeed
+*   - nil, error: if fail
+ */
+func NewValueExtractor(re *regexp.Regexp, item ItemConfig) (*ValueExtractor, error) {
+	extractor := new(ValueExtractor)
+
+	groups := item.Groups
+	if len(groups) == 0 {
+		groups = []string{"1"}
+	}
+
+	for _, group := range groups {
+		idx, err := strconv.Atoi(group)
+		if err != nil {
+			idx = re.SubexpIndex(group)
+			if idx < 0 {
+				return nil, fmt.Errorf("group %s of item %s is not found in pattern", group, item.Metric)
+			}
+		}
+		if idx < 0 || idx > re.NumSubexp() {
+			return nil, fmt.Errorf("group %s of item %s is out of range, pattern has %d groups", group, item.Metric, re.NumSubexp())
+		}
+		extractor.Groups = append(extractor.Groups, idx)
+	}
+
+	switch item.Combine {
+	case "":
+		extractor.Combine = COMBINE_SUM
+	case COMBINE_SUM, COMBINE_MAX, COMBINE_MIN, COMBINE_AVG, COMBINE_FIRST, COMBINE_LAST:
+		extractor.Combine = item.Combine
+	default:
+		return nil, fmt.Errorf("Combine of item should be 'sum'/'max'/'min'/'avg'/'first'/'last'")
+	}
+
+	extractor.Separator = item.Separator
+
+	transform, err := NewTransform(item.Transform)
+	if err != nil {
+		return nil, err
+	}
+	extractor.Transform = transform
+
+	return extractor, nil
+}
+
+/*
+* Value - combine the selected groups into one cost value
+*
+* RECEIVER: *ValueExtractor
+*
+* PARAMS:
+*   - matches: submatches of the pattern
+*
+* RETURNS:
+*   - value, nil: if succeed
+*   - 0, error: if fail
+ */
+func (extractor *ValueExtractor) Value(matches [][]byte) (float64, error) {
+	var values []float64
+
+	for _, idx := range extractor.Groups {
+		// the group did not participate in the match
+		if matches[idx] == nil {
+			continue
+		}
+
+		// every character of separator splits the group, e.g. ',:' for nginx upstream_time
+		elements := [][]byte{matches[idx]}
+		if extractor.Separator != "" {
+			elements = bytes.FieldsFunc(matches[idx], func(r rune) bool {
+				return strings.ContainsRune(extractor.Separator, r)
+			})
+		}
+
+		for _, element := range elements {
+			if strings.TrimSpace(string(element)) == "" {
+				continue
+			}
+			value, err := extractor.Transform.Parse(element)
+			if err != nil {
+				return 0, err
+			}
+			values = append(values, value)
+		}
+	}
+
+	if len(values) == 0 {
+		return 0, fmt.Errorf("no value is extracted")
+	}
+
+	return extractor.Transform.Apply(combine(values, extractor.Combine))
+}
+
+/*
+* combine - combine values into one
+*
+* PARAMS:
+*   - values: values to combine, at least one
+*   - method: 'sum'/'max'/'min'/'avg'/'first'/'last'
+*
+* RETURNS:
+*   - combined value
+ */
+func combine(values []float64, method string) float64 {
+	result := values[0]
+	switch method {
+	case COMBINE_SUM, COMBINE_AVG:
+		for _, v := range values[1:] {
+			result += v
+		}
+		if method == COMBINE_AVG {
+			result = result / float64(len(values))
+		}
+	case COMBINE_MAX:
+		for _, v := range values[1:] {
+			if v > result {
+				result = v
+			}
+		}
+	case COMBINE_MIN:
+		for _, v := range values[1:] {
+			if v < result {
+				result = v
+			}
+		}
+	case COMBINE_LAST:
+		result = values[len(values)-1]
+	}
+	return result
+}
@@ -0,0 +1,244 @@
+/*
+* reader.go - functions to read whole log files
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the functions to open a log file with transparent
+* gzip/zstd decompression, find the rotated siblings of a log file and
+* split a stream into lines by delimiter
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	MAX_LINE_SIZE = 16 * 1024 * 1024
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+type zstdCloser struct {
+	decoder *zstd.Decoder
+}
+
+/*
+* Close - release the zstd decoder
+*
+* RECEIVER: zstdCloser
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   nil
+ */
+func (c zstdCloser) Close() error {
+	c.decoder.Close()
+	return nil
+}
+
+type logReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+/*
+* Close - close the decompressor and the file
+*
+* RECEIVER: *logReader
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   nil, if succeed
+*   error, if fail
+ */
+func (r *logReader) Close() error {
+	var err error
+	for idx := len(r.closers) - 1; idx >= 0; idx-- {
+		if e := r.closers[idx].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+/*
+* OpenLog - open a log file, decompress it if it is gzip or zstd compressed
+*
+* PARAMS:
+*   - filename: path of log file
+*
+* RETURNS:
+*   - io.ReadCloser, nil: if succeed
+*   - nil, error: if fail
+ */
+func OpenLog(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(4)
+
+	if bytes.HasPrefix(magic, gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &logReader{Reader: gz, closers: []io.Closer{file, gz}}, nil
+	}
+
+	if bytes.HasPrefix(magic, zstdMagic) {
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &logReader{Reader: decoder, closers: []io.Closer{file, zstdCloser{decoder}}}, nil
+	}
+
+	return &logReader{Reader: buffered, closers: []io.Closer{file}}, nil
+}
+
+/*
+* IsCompressed - check whether a log file is compressed
+*
+* PARAMS:
+*   - filename: path of log file
+*
+* RETURNS:
+*   - true: if the file is gzip or zstd compressed
+*   - false: if not
+ */
+func IsCompressed(filename string) bool {
+	if strings.HasSuffix(filename, ".gz") || strings.HasSuffix(filename, ".zst") {
+		return true
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(file, magic)
+	return bytes.HasPrefix(magic[:n], gzipMagic) || bytes.HasPrefix(magic[:n], zstdMagic)
+}
+
+// suffix of rotated file: a number or a date, maybe with a sequence, maybe compressed
+// app.log.1, app.log.2.gz, app.log-20261019, app.log.2026-10-19_10, app.log-20261019-1697700000.zst
+var rotatedSuffix = regexp.MustCompile(`^[._-](\d+|\d{4}-\d{2}-\d{2}([._T-]\d{2}([:.-]?\d{2}){0,2})?)([._-]\d+)?(\.gz|\.zst)?$`)
+
+/*
+* RotatedFiles - find the log file and its rotated siblings
+*
+* PARAMS:
+*   - filename: path of log file
+*
+* RETURNS:
+*   - []string: files ordered from the oldest to the newest, the files
+*     next to the log without a rotated suffix (.swp/.tmp) are not included
+ */
+func RotatedFiles(filename string) []string {
+	type candidate struct {
+		name    string
+		modTime int64
+	}
+
+	var candidates []candidate
+	seen := make(map[string]bool)
+
+	var names []string
+	matches, err := filepath.Glob(filename + "?*")
+	if err == nil {
+		for _, name := range matches {
+			suffix := strings.TrimPrefix(filepath.Base(name), filepath.Base(filename))
+			if rotatedSuffix.MatchString(suffix) {
+				names = append(names, name)
+			}
+		}
+	}
+	names = append(names, filename)
+
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		fileinfo, err := os.Stat(name)
+		if err != nil || !fileinfo.Mode().IsRegular() {
+			continue
+		}
+		candidates = append(candidates, candidate{name, fileinfo.ModTime().UnixNano()})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].modTime < candidates[j].modTime
+	})
+
+	var files []string
+	for _, one := range candidates {
+		files = append(files, one.name)
+	}
+	return files
+}
+
+/*
+* ReadLines - split a stream into lines by delimiter
+*
+* PARAMS:
+*   - r: stream
+*   - delimiter: line delimiter, '\n' if empty
+*   - unit: size of code unit, the delimiter begins on its boundaries
+*   - fn: function to process each line with the delimiter
+*
+* RETURNS:
+*   nil, if the stream ends
+*   error, if fail
+ */
+func ReadLines(r io.Reader, delimiter string, unit int, fn func(line []byte)) error {
+	if delimiter == "" {
+		delimiter = "\n"
+	}
+	sep := []byte(delimiter)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MAX_LINE_SIZE)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if idx := IndexSeparator(data, sep, unit); idx >= 0 {
+			return idx + len(sep), data[:idx+len(sep)], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+
+	for scanner.Scan() {
+		fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
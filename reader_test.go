@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRotatedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// from the oldest to the newest
+	rotated := []string{
+		"app.log-20261017-1697500000.zst",
+		"app.log-20261018.gz",
+		"app.log.2026-10-19_09",
+		"app.log.2",
+		"app.log_3",
+		"app.log.1",
+		"app.log",
+	}
+	others := []string{
+		"app.log.swp",
+		"app.log.tmp",
+		"app.log.1.swp",
+		"app.log-old",
+		"app.logger",
+		".app.log.swp",
+		"app.log.2026-10-19.bak",
+	}
+
+	now := time.Now()
+	for idx, name := range append(rotated, others...) {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, []byte("line\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(time.Duration(idx-len(rotated)) * time.Minute)
+		if err := os.Chtimes(filename, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var want []string
+	for _, name := range rotated {
+		want = append(want, filepath.Join(dir, name))
+	}
+	if files := RotatedFiles(filepath.Join(dir, "app.log")); !reflect.DeepEqual(files, want) {
+		t.Errorf("RotatedFiles = %q, want %q", files, want)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+* reload.go - apply the changed configuration to the running agents
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the incremental reload which compares the logs of
+* the new configuration with the running ones by name, keeps the agents
+* of unchanged logs running, restarts the changed ones with the file
+* offset and the windows of unchanged items carried over, stops and
+* flushes the removed ones and starts the new ones
+ */
+
+package main
+
+import (
+	"log"
+	"math"
+	"reflect"
+)
+
+/*
+* ReloadAgent - restart the agents whose log configuration changed
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func ReloadAgent() {
+	running := make(map[string]*Record)
+	for _, record := range records {
+		running[record.Name] = record
+	}
+
+	var reloaded []*Record
+	for _, one := range config.Logs {
+		old, ok := running[one.Name]
+		delete(running, one.Name)
+
+		if ok && reflect.DeepEqual(old.Config, one) {
+			reloaded = append(reloaded, old)
+			continue
+		}
+
+		if ok {
+			log.Printf("log %s is changed, restart its agent", one.Name)
+			old.Stop()
+		} else {
+			log.Printf("log %s is added, start its agent", one.Name)
+		}
+
+		record, err := NewRecord(one)
+		if err != nil {
+			log.Printf("agent of %s creating FAIL: %v", one.Name, err)
+			if ok {
+				old.Agent.Expire(math.MaxInt64)
+			}
+			continue
+		}
+		if ok {
+			record.Agent.Inherit(old.Agent, old.Config, one)
+		}
+
+		record.Start()
+		reloaded = append(reloaded, record)
+	}
+
+	// the agents left are not in configuration any more
+	for name, old := range running {
+		log.Printf("log %s is removed, stop its agent", name)
+		old.Stop()
+		old.Agent.Expire(math.MaxInt64)
+	}
+
+	records = reloaded
+}
+
+/*
+* Inherit - take over the state of the agent stopped for reload
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - old: the stopped agent
+*   - before: log configuration of the stopped agent
+*   - after: log configuration of this agent
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) Inherit(old *FileAgent, before LogConfig, after LogConfig) {
+	// only a file can be read on from where the old agent stopped
+	sameInput := fa.Type == LOG_TYPE_FILE && old.Type == LOG_TYPE_FILE &&
+		fa.Filename == old.Filename &&
+		before.Delimiter == after.Delimiter && before.Encoding == after.Encoding
+	if sameInput {
+		fa.FileInfo = old.FileInfo
+		fa.LastOffset = old.LastOffset
+	}
+
+	// the windows are valid while the lines are timed the same way
+	sameClock := sameInput &&
+		before.TsEnabled == after.TsEnabled && before.TsPattern == after.TsPattern &&
+		before.Lateness == after.Lateness
+	if sameClock {
+		fa.Watermark = old.Watermark
+		fa.TsUpdate = old.TsUpdate
+		fa.LastData = old.LastData
+		fa.StatusTime = old.StatusTime
+	}
+
+	carried := make(map[*AgentTask]bool)
+	if sameClock {
+		for idx, item := range after.Items {
+			for prev, oldItem := range before.Items {
+				task := old.Tasks[prev]
+				if !carried[task] && reflect.DeepEqual(item, oldItem) {
+					fa.Tasks[idx] = task
+					carried[task] = true
+					break
+				}
+			}
+		}
+		fa.Prefilter = NewPrefilter(fa.Tasks)
+	}
+
+	// report the windows of the items changed or removed
+	var data []*FalconData
+	for _, task := range old.Tasks {
+		if carried[task] {
+			continue
+		}
+		for _, serie := range task.AllSeries() {
+			for _, window := range serie.Expire(math.MaxInt64) {
+				data = append(data, serie.Report(window)...)
+			}
+		}
+	}
+	fa.Push(data)
+
+	log.Printf("agent of %s inherits offset %d and %d of %d items", fa.Name, fa.LastOffset, len(carried), len(fa.Tasks))
+}
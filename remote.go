@@ -0,0 +1,170 @@
+/*
+* remote.go - fetch the configuration from a central config service
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the remote configuration source which gets the
+* configuration from an http url periodically, with the ETag of the last
+* one and the identity of host in query, validates it as the local file
+* and saves the valid one to the configuration file as a local cache,
+* which is then applied by the normal reload
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// seconds between two fetches
+	DEFAULT_REMOTE_INTERVAL = 60
+
+	REMOTE_TIMEOUT = 10
+
+	// the configuration larger than it is taken as broken
+	MAX_REMOTE_SIZE = 10 * 1024 * 1024
+)
+
+type RemoteSource struct {
+	Url      string
+	ETag     string
+	ETagFile string
+	Client   *http.Client
+}
+
+/*
+* NewRemoteSource - generate the remote configuration source
+*
+* PARAMS:
+*   - rawurl: url of config service
+*
+* RETURNS:
+*   - *RemoteSource, nil: if succeed
+*   - nil, error: if the url is malformed
+ */
+func NewRemoteSource(rawurl string) (*RemoteSource, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme of %s should be 'http' or 'https'", rawurl)
+	}
+
+	// the config service tells the hosts apart by the query
+	query := u.Query()
+	query.Set("hostname", GetEndpoint(""))
+	query.Set("ip", LocalIP())
+	query.Set("version", version)
+	u.RawQuery = query.Encode()
+
+	rs := new(RemoteSource)
+	rs.Url = u.String()
+	rs.ETagFile = configFile + ".etag"
+	rs.Client = &http.Client{Timeout: REMOTE_TIMEOUT * time.Second}
+
+	// the ETag is valid only while its configuration is cached
+	if _, err := os.Stat(configFile); err == nil {
+		if buf, err := ioutil.ReadFile(rs.ETagFile); err == nil {
+			rs.ETag = strings.TrimSpace(string(buf))
+		}
+	}
+
+	return rs, nil
+}
+
+/*
+* Fetch - get the configuration and cache it when it is changed and valid
+*
+* RECEIVER: *RemoteSource
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - true, nil: if a new configuration is cached
+*   - false, nil: if the configuration is not modified
+*   - false, error: if fail, the cached configuration is kept
+ */
+func (rs *RemoteSource) Fetch() (bool, error) {
+	request, err := http.NewRequest("GET", rs.Url, nil)
+	if err != nil {
+		return false, err
+	}
+	if rs.ETag != "" {
+		request.Header.Set("If-None-Match", rs.ETag)
+	}
+
+	response, err := rs.Client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("config service responds %s", response.Status)
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(response.Body, MAX_REMOTE_SIZE+1))
+	if err != nil {
+		return false, err
+	}
+	if len(buf) > MAX_REMOTE_SIZE {
+		return false, fmt.Errorf("configuration is larger than %d bytes", MAX_REMOTE_SIZE)
+	}
+
+	// validated by the rules of local file, a broken one never replaces the cache
+	if cfg := LoadConfigData(buf); cfg == nil {
+		return false, fmt.Errorf("configuration of config service is invalid")
+	}
+
+	if err := WriteFileAtomic(configFile, buf); err != nil {
+		return false, err
+	}
+
+	rs.ETag = response.Header.Get("ETag")
+	if rs.ETag != "" {
+		if err := WriteFileAtomic(rs.ETagFile, []byte(rs.ETag+"\n")); err != nil {
+			log.Printf("etag file %s writing FAIL: %v", rs.ETagFile, err)
+		}
+	} else {
+		os.Remove(rs.ETagFile)
+	}
+
+	log.Printf("configuration of config service is cached to %s, etag %s", configFile, rs.ETag)
+	return true, nil
+}
+
+/*
+* WriteFileAtomic - write a temporary file and rename it
+*
+* PARAMS:
+*   - filename: path of file
+*   - buf: content of file
+*
+* RETURNS:
+*   - nil: if succeed
+*   - error: if fail
+ */
+func WriteFileAtomic(filename string, buf []byte) error {
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
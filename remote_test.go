@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const remoteValidConfig = `
+falcon:
+  url: http://127.0.0.1:1988/v1/push
+logs:
+  - name: test
+    path: /var/log/test.log
+    items:
+      - metric: test.error
+        pattern: ERROR
+        method: count
+        step: 60
+        counterType: GAUGE
+`
+
+// falcon url is missing
+const remoteInvalidConfig = `
+logs:
+  - name: test
+    path: /var/log/test.log
+`
+
+type remoteResponse struct {
+	status int
+	etag   string
+	body   string
+}
+
+type remoteRequest struct {
+	query       url.Values
+	ifNoneMatch string
+}
+
+func newRemoteServer(responses []remoteResponse, requests *[]remoteRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, remoteRequest{query: r.URL.Query(), ifNoneMatch: r.Header.Get("If-None-Match")})
+		response := responses[len(*requests)-1]
+		if response.etag != "" {
+			w.Header().Set("ETag", response.etag)
+		}
+		w.WriteHeader(response.status)
+		w.Write([]byte(response.body))
+	}))
+}
+
+func useTempConfigFile(t *testing.T) func() {
+	log.SetOutput(ioutil.Discard)
+	dir, err := ioutil.TempDir("", "log-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := configFile
+	configFile = filepath.Join(dir, "config.yaml")
+	return func() {
+		configFile = saved
+		os.RemoveAll(dir)
+	}
+}
+
+func readFile(t *testing.T, filename string) string {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filename, err)
+	}
+	return string(buf)
+}
+
+func TestRemoteFetch(t *testing.T) {
+	defer useTempConfigFile(t)()
+
+	var requests []remoteRequest
+	server := newRemoteServer([]remoteResponse{
+		{http.StatusOK, `"v1"`, remoteValidConfig},
+		{http.StatusNotModified, "", ""},
+		{http.StatusOK, `"v2"`, remoteInvalidConfig},
+		{http.StatusInternalServerError, "", "down"},
+	}, &requests)
+	defer server.Close()
+
+	rs, err := NewRemoteSource(server.URL + "/config?group=web")
+	if err != nil {
+		t.Fatalf("NewRemoteSource: %v", err)
+	}
+
+	// 200: the configuration and its etag are cached
+	if changed, err := rs.Fetch(); !changed || err != nil {
+		t.Fatalf("Fetch 200 = %v, %v", changed, err)
+	}
+	if content := readFile(t, configFile); content != remoteValidConfig {
+		t.Fatalf("cached configuration = %q", content)
+	}
+	if etag := readFile(t, configFile+".etag"); etag != "\"v1\"\n" {
+		t.Fatalf("cached etag = %q", etag)
+	}
+
+	query := requests[0].query
+	if query.Get("group") != "web" || query.Get("hostname") != GetEndpoint("") || query.Get("version") != version {
+		t.Errorf("query = %v", query)
+	}
+	if _, ok := query["ip"]; !ok || query.Get("ip") != LocalIP() {
+		t.Errorf("ip of query = %v, want %q", query["ip"], LocalIP())
+	}
+	if requests[0].ifNoneMatch != "" {
+		t.Errorf("If-None-Match of the first request = %q", requests[0].ifNoneMatch)
+	}
+
+	// 304: the etag is sent, nothing is changed
+	if changed, err := rs.Fetch(); changed || err != nil {
+		t.Fatalf("Fetch 304 = %v, %v", changed, err)
+	}
+	if requests[1].ifNoneMatch != `"v1"` {
+		t.Errorf("If-None-Match = %q, want %q", requests[1].ifNoneMatch, `"v1"`)
+	}
+
+	// invalid configuration: the cache is kept
+	if changed, err := rs.Fetch(); changed || err == nil {
+		t.Fatalf("Fetch invalid = %v, %v", changed, err)
+	}
+	if content := readFile(t, configFile); content != remoteValidConfig {
+		t.Fatalf("cache is replaced by the invalid configuration: %q", content)
+	}
+	if rs.ETag != `"v1"` {
+		t.Errorf("etag = %q after the invalid configuration", rs.ETag)
+	}
+
+	// other status: an error, the cache is kept
+	if changed, err := rs.Fetch(); changed || err == nil {
+		t.Fatalf("Fetch 500 = %v, %v", changed, err)
+	}
+	if content := readFile(t, configFile); content != remoteValidConfig {
+		t.Fatalf("cache is replaced after 500: %q", content)
+	}
+}
+
+func TestRemoteSourceCachedETag(t *testing.T) {
+	defer useTempConfigFile(t)()
+
+	if err := ioutil.WriteFile(configFile+".etag", []byte("\"v1\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the etag without its configuration is not used
+	rs, err := NewRemoteSource("http://127.0.0.1/config")
+	if err != nil {
+		t.Fatalf("NewRemoteSource: %v", err)
+	}
+	if rs.ETag != "" {
+		t.Errorf("etag = %q without cached configuration", rs.ETag)
+	}
+
+	if err := ioutil.WriteFile(configFile, []byte(remoteValidConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if rs, err = NewRemoteSource("http://127.0.0.1/config"); err != nil {
+		t.Fatalf("NewRemoteSource: %v", err)
+	}
+	if rs.ETag != `"v1"` {
+		t.Errorf("etag = %q, want %q", rs.ETag, `"v1"`)
+	}
+
+	if _, err := NewRemoteSource("ftp://127.0.0.1/config"); err == nil {
+		t.Errorf("ftp scheme is accepted")
+	}
+}
@@ -0,0 +1,119 @@
+/*
+* status.go - the status metrics of file agent
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the functions to generate the status metrics of
+* the log file, so that "no error" can be told from "no log"
+* agent.file.missing - 1 if the log file/pipe is not open, the listener is down
+*                      or no container log is found
+* agent.file.stale - 1 if no new data is read for staleAfter seconds
+* agent.decode.failed - lines dropped for not decoding in the encoding of log
+* agent.series.dropped - entries of an item with tagFields dropped for having
+*                        more than MAX_SERIES series
+* the state of agent is also dumped to the log on SIGUSR1
+ */
+
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	STATUS_STEP = 60
+
+	DEFAULT_STALE_AFTER = 300
+)
+
+/*
+* Status - generate the status metrics of log file
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - now: current timestamp
+*
+* RETURNS:
+*   - []*FalconData: status data points
+ */
+func (fa *FileAgent) Status(now int64) []*FalconData {
+	var data []*FalconData
+
+	tags := "log=" + fa.Name
+	falcon := CurrentFalcon()
+
+	open := fa.File != nil || fa.Listener != nil || fa.Stream != nil || len(fa.Containers) > 0
+
+	missing := 0
+	if !open {
+		missing = 1
+	}
+	point := NewFalconData("agent.file.missing", falcon.Endpoint, missing, "GAUGE", tags, now, STATUS_STEP)
+	data = append(data, point)
+
+	stale := 0
+	if open && now-fa.LastData >= fa.StaleAfter {
+		stale = 1
+	}
+	point = NewFalconData("agent.file.stale", falcon.Endpoint, stale, "GAUGE", tags, now, STATUS_STEP)
+	data = append(data, point)
+
+	// lines dropped since the last status for decoding failure
+	point = NewFalconData("agent.decode.failed", falcon.Endpoint, fa.DecodeFail, "GAUGE", tags, now, STATUS_STEP)
+	data = append(data, point)
+	fa.DecodeFail = 0
+
+	// entries dropped since the last status for too many series
+	for _, task := range fa.Tasks {
+		if len(task.TagFields) == 0 {
+			continue
+		}
+		point = NewFalconData("agent.series.dropped", falcon.Endpoint, task.Dropped, "GAUGE", tags+",metric="+task.Metric, now, STATUS_STEP)
+		data = append(data, point)
+		task.Dropped = 0
+	}
+
+	return data
+}
+
+/*
+* Dump - write the reading state and the open windows of agent to the log
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) Dump() {
+	open := fa.File != nil || fa.Listener != nil || fa.Stream != nil || len(fa.Containers) > 0
+
+	var size int64
+	if fa.FileInfo != nil {
+		size = fa.FileInfo.Size()
+	}
+	log.Printf("dump %s: type %s, path %s, open %v, offset %d/%d, containers %d, watermark %d, last data %s",
+		fa.Name, fa.Type, fa.Filename, open, fa.LastOffset, size, len(fa.Containers), fa.Watermark,
+		time.Unix(fa.LastData, 0).Format("2006-01-02 15:04:05"))
+	if fa.Cursor != "" {
+		log.Printf("dump %s: cursor %s, saved %v", fa.Name, fa.Cursor, fa.Cursor == fa.SavedCursor)
+	}
+
+	for _, task := range fa.Tasks {
+		series := task.AllSeries()
+		log.Printf("dump %s: item %s (%s), %d series", fa.Name, task.Metric, task.Method, len(series))
+		for _, serie := range series {
+			for _, window := range serie.Windows {
+				log.Printf("dump %s: item %s tags %q window %d~%d, %d values, late %d, flushed %d",
+					fa.Name, serie.Metric, serie.Tags, window.TsStart, window.TsEnd, window.ValueCnt, serie.LateCnt, serie.Flushed)
+			}
+		}
+	}
+}
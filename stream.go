@@ -0,0 +1,369 @@
+/*
+* stream.go - stdin and named pipe input
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the streaming input which reads stdin or a named
+* pipe continuously, without the size and seek a regular file offers,
+* splits the stream into lines and matches them with the tasks of agent
+* stdin can be read only once, so it is broadcast to the agents reading it
+ */
+
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	STREAM_CHUNK_SIZE = 64 * 1024
+
+	// path of stdin in configuration
+	STDIN_PATH = "-"
+)
+
+type StdinHub struct {
+	sync.Mutex
+	Subscribers map[*StdinSubscriber]bool
+	Started     bool
+	Closed      bool
+	Done        chan bool
+	doneOnce    sync.Once
+}
+
+type StdinSubscriber struct {
+	Chunks chan []byte
+	done   chan bool
+}
+
+var stdinHub = &StdinHub{
+	Subscribers: make(map[*StdinSubscriber]bool),
+	Done:        make(chan bool),
+}
+
+/*
+* Subscribe - receive the chunks of stdin, start reading on the first call
+*
+* RECEIVER: *StdinHub
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - *StdinSubscriber: its channel is closed when stdin ends
+ */
+func (hub *StdinHub) Subscribe() *StdinSubscriber {
+	hub.Lock()
+	defer hub.Unlock()
+
+	sub := &StdinSubscriber{
+		Chunks: make(chan []byte, 16),
+		done:   make(chan bool),
+	}
+	if hub.Closed {
+		close(sub.Chunks)
+		return sub
+	}
+	hub.Subscribers[sub] = true
+
+	if !hub.Started {
+		hub.Started = true
+		go hub.run()
+	}
+	return sub
+}
+
+/*
+* Close - stop receiving the chunks of stdin
+*
+* RECEIVER: *StdinSubscriber
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   nil
+ */
+func (sub *StdinSubscriber) Close() error {
+	close(sub.done)
+
+	stdinHub.Lock()
+	defer stdinHub.Unlock()
+
+	delete(stdinHub.Subscribers, sub)
+	if stdinHub.Closed && len(stdinHub.Subscribers) == 0 {
+		stdinHub.finish()
+	}
+	return nil
+}
+
+/*
+* finish - close Done, the subscribers and run may all find stdin is over
+*
+* RECEIVER: *StdinHub
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (hub *StdinHub) finish() {
+	hub.doneOnce.Do(func() {
+		close(hub.Done)
+	})
+}
+
+/*
+* run - read stdin and broadcast the chunks until it ends
+*
+* RECEIVER: *StdinHub
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (hub *StdinHub) run() {
+	buf := make([]byte, STREAM_CHUNK_SIZE)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			hub.Lock()
+			var subs []*StdinSubscriber
+			for sub := range hub.Subscribers {
+				subs = append(subs, sub)
+			}
+			hub.Unlock()
+
+			for _, sub := range subs {
+				chunk := make([]byte, n)
+				copy(chunk, buf[:n])
+				select {
+				case sub.Chunks <- chunk:
+				case <-sub.done:
+				}
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("stdin reading FAIL: %v", err)
+			}
+			break
+		}
+	}
+
+	hub.Lock()
+	defer hub.Unlock()
+
+	log.Printf("stdin is closed")
+	hub.Closed = true
+	for sub := range hub.Subscribers {
+		close(sub.Chunks)
+	}
+	if len(hub.Subscribers) == 0 {
+		hub.finish()
+	}
+}
+
+/*
+* TailStream - read stdin or a named pipe in a loop
+*
+* PARAMS:
+*   - fa: file agent of stream input
+*   - finish: a channel to receiver stop signal
+*
+* RETURNS:
+*   No return value
+ */
+func TailStream(fa *FileAgent, finish <-chan bool) {
+	log.Printf("agent for %s is launching...", fa.Filename)
+
+	// create one second ticker
+	ticker := time.NewTicker(time.Second * 1)
+	defer ticker.Stop()
+
+	done := make(chan bool)
+	defer close(done)
+
+	// the windows are kept when the stream is opened again
+	fa.ResetTasks()
+	chunks, err := fa.StreamOpen(done)
+	if err != nil {
+		log.Printf("stream %s open FAIL: %v", fa.Filename, err)
+	}
+
+STREAM:
+	for {
+		select {
+		case <-finish:
+			fa.StreamClose()
+			break STREAM
+		case chunk, ok := <-chunks:
+			if ok {
+				fa.ProcessChunk(chunk)
+				continue
+			}
+
+			// the writer is gone, the line left is complete
+			if len(fa.Pending) > 0 {
+				fa.ProcessLine(fa.Pending)
+				fa.Pending = nil
+			}
+			fa.StreamClose()
+			chunks = nil
+
+			// nothing more comes from stdin, report all windows and exit
+			if fa.Filename == STDIN_PATH {
+				fa.Expire(math.MaxInt64)
+				break STREAM
+			}
+		case <-ticker.C:
+			// retry when the pipe is not created yet
+			if fa.Stream == nil {
+				if chunks, err = fa.StreamOpen(done); err != nil {
+					log.Printf("stream %s open FAIL: %v", fa.Filename, err)
+				}
+			}
+			fa.Timeup()
+		}
+	}
+
+	wg.Done()
+	log.Printf("agent for %s is exiting...", fa.Filename)
+}
+
+/*
+* StreamOpen - subscribe stdin or open the named pipe
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - done: a channel closed when agent exits
+*
+* RETURNS:
+*   - <-chan []byte, nil: chunks of stream if succeed
+*   - nil, error: if fail
+ */
+func (fa *FileAgent) StreamOpen(done <-chan bool) (<-chan []byte, error) {
+	fa.Pending = nil
+
+	if fa.Filename == STDIN_PATH {
+		sub := stdinHub.Subscribe()
+		fa.Stream = sub
+		return sub.Chunks, nil
+	}
+
+	// opened for writing too, so the pipe does not end between writers
+	// and the open does not block until a writer comes
+	file, err := os.OpenFile(fa.Filename, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	fa.Stream = file
+
+	chunks := make(chan []byte, 16)
+	go ReadStream(file, chunks, done)
+	return chunks, nil
+}
+
+/*
+* StreamClose - stop reading the stream
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) StreamClose() {
+	if fa.Stream == nil {
+		return
+	}
+	if err := fa.Stream.Close(); err != nil {
+		log.Printf("stream %s closing FAIL: %v", fa.Filename, err)
+	}
+	fa.Stream = nil
+}
+
+/*
+* ReadStream - read chunks of a stream until it ends or fails
+*
+* PARAMS:
+*   - r: stream
+*   - chunks: channel of chunks, closed when the stream ends
+*   - done: a channel closed when agent exits
+*
+* RETURNS:
+*   No return value
+ */
+func ReadStream(r io.Reader, chunks chan<- []byte, done <-chan bool) {
+	defer close(chunks)
+
+	buf := make([]byte, STREAM_CHUNK_SIZE)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			select {
+			case chunks <- chunk:
+			case <-done:
+				return
+			}
+		}
+		if err != nil {
+			// the stream is closed by agent when it exits
+			if err != io.EOF && !errors.Is(err, os.ErrClosed) {
+				log.Printf("stream reading FAIL: %v", err)
+			}
+			return
+		}
+	}
+}
+
+/*
+* ProcessChunk - split the chunk into lines and match them
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - chunk: bytes read from stream
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) ProcessChunk(chunk []byte) {
+	fa.LastData = time.Now().Unix()
+	fa.Pending = append(fa.Pending, chunk...)
+
+	sep := fa.Separator()
+	for {
+		idx := IndexSeparator(fa.Pending, sep, fa.CodeUnit)
+		if idx < 0 {
+			break
+		}
+		fa.ProcessLine(fa.Pending[:idx+len(sep)])
+		fa.Pending = fa.Pending[idx+len(sep):]
+	}
+
+	// a line without delimiter for too long
+	if len(fa.Pending) > MAX_LINE_SIZE {
+		log.Printf("line of %s is longer than %d, match it as a whole", fa.Filename, MAX_LINE_SIZE)
+		fa.ProcessLine(fa.Pending)
+		fa.Pending = nil
+	}
+}
@@ -0,0 +1,559 @@
+/*
+* syslog.go - syslog receiver input
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the syslog listener which receives RFC3164 and
+* RFC5424 messages over UDP or TCP and matches the message of each one
+* with the tasks of agent, program/facility/severity/hostname are passed
+* as the fields of entry for the match and tagFields of items
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SYSLOG_MAX_MESSAGE = 64 * 1024
+	SYSLOG_QUEUE_SIZE  = 1024
+
+	// facility user, severity notice
+	SYSLOG_DEFAULT_PRI = 13
+)
+
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var syslogSeverities = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+type SyslogMessage struct {
+	Facility string
+	Severity string
+	Hostname string
+	Program  string
+	Time     int64
+	Message  []byte
+}
+
+/*
+* ParseListen - parse the listen address of syslog
+*
+* PARAMS:
+*   - listen: 'udp://host:port' or 'tcp://host:port', udp if no scheme
+*
+* RETURNS:
+*   - network, address, nil: if succeed
+*   - "", "", error: if fail
+ */
+func ParseListen(listen string) (string, string, error) {
+	if listen == "" {
+		return "", "", fmt.Errorf("listen address should not EMPTY")
+	}
+
+	network := "udp"
+	address := listen
+	if idx := strings.Index(listen, "://"); idx >= 0 {
+		network = listen[:idx]
+		address = listen[idx+3:]
+	}
+
+	if network != "udp" && network != "tcp" {
+		return "", "", fmt.Errorf("network %s of listen should be 'udp' or 'tcp'", network)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", "", err
+	}
+
+	return network, address, nil
+}
+
+/*
+* ParseSyslog - parse a syslog message in RFC5424 or RFC3164 format
+*
+* PARAMS:
+*   - data: one message without framing
+*
+* RETURNS:
+*   - *SyslogMessage
+ */
+func ParseSyslog(data []byte) *SyslogMessage {
+	data = bytes.TrimRight(data, "\r\n\x00")
+
+	pri := SYSLOG_DEFAULT_PRI
+	if len(data) > 2 && data[0] == '<' {
+		// PRI is 1 to 3 digits, no sign, or the message has no PRI
+		if end := bytes.IndexByte(data, '>'); end > 1 && end <= 4 {
+			if value, ok := parsePri(data[1:end]); ok {
+				pri = value
+				data = data[end+1:]
+			}
+		}
+	}
+
+	msg := new(SyslogMessage)
+	msg.Facility = syslogFacilities[pri/8]
+	msg.Severity = syslogSeverities[pri%8]
+
+	// RFC5424 has a version after the priority
+	if len(data) > 1 && data[0] >= '1' && data[0] <= '9' && data[1] == ' ' {
+		msg.parse5424(data[2:])
+	} else {
+		msg.parse3164(data)
+	}
+
+	return msg
+}
+
+/*
+* parsePri - parse the digits of PRI
+*
+* PARAMS:
+*   - digits: bytes between '<' and '>'
+*
+* RETURNS:
+*   - value, true: if the digits are a valid PRI
+*   - 0, false: if not
+ */
+func parsePri(digits []byte) (int, bool) {
+	value := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		value = value*10 + int(c-'0')
+	}
+	if value >= len(syslogFacilities)*len(syslogSeverities) {
+		return 0, false
+	}
+	return value, true
+}
+
+/*
+* parse5424 - parse the header and message of RFC5424
+*
+* RECEIVER: *SyslogMessage
+*
+* PARAMS:
+*   - data: message after the version
+*
+* RETURNS:
+*   No return value
+ */
+func (msg *SyslogMessage) parse5424(data []byte) {
+	// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID
+	var header [5]string
+	for idx := range header {
+		end := bytes.IndexByte(data, ' ')
+		if end < 0 {
+			end = len(data) - 1
+		}
+		header[idx] = string(data[:end+1])
+		header[idx] = strings.TrimSuffix(header[idx], " ")
+		if header[idx] == "-" {
+			header[idx] = ""
+		}
+		data = data[end+1:]
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, header[0]); err == nil {
+		msg.Time = t.Unix()
+	}
+	msg.Hostname = header[1]
+	msg.Program = header[2]
+
+	// STRUCTURED-DATA is '-' or some [...] elements
+	if len(data) > 0 && data[0] == '-' {
+		data = data[1:]
+	}
+	for len(data) > 0 && data[0] == '[' {
+		end := structuredDataEnd(data)
+		data = data[end:]
+	}
+
+	data = bytes.TrimPrefix(data, []byte(" "))
+	msg.Message = bytes.TrimPrefix(data, utf8BOM)
+}
+
+/*
+* structuredDataEnd - find the end of a structured data element
+*
+* PARAMS:
+*   - data: bytes beginning with '['
+*
+* RETURNS:
+*   - index after the closing ']'
+ */
+func structuredDataEnd(data []byte) int {
+	quoted := false
+	for idx := 1; idx < len(data); idx++ {
+		switch data[idx] {
+		case '\\':
+			idx++
+		case '"':
+			quoted = !quoted
+		case ']':
+			if !quoted {
+				return idx + 1
+			}
+		}
+	}
+	return len(data)
+}
+
+/*
+* parse3164 - parse the header and message of RFC3164
+*
+* RECEIVER: *SyslogMessage
+*
+* PARAMS:
+*   - data: message after the priority
+*
+* RETURNS:
+*   No return value
+ */
+func (msg *SyslogMessage) parse3164(data []byte) {
+	if len(data) >= len(time.Stamp) {
+		if t, err := time.ParseInLocation(time.Stamp, string(data[:len(time.Stamp)]), time.Local); err == nil {
+			// the year is not in the message
+			now := time.Now()
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.AddDate(0, 0, 1)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			msg.Time = t.Unix()
+
+			data = bytes.TrimLeft(data[len(time.Stamp):], " ")
+
+			// the hostname is omitted by some senders
+			if end := bytes.IndexByte(data, ' '); end > 0 && !bytes.ContainsAny(data[:end], ":[") {
+				msg.Hostname = string(data[:end])
+				data = data[end+1:]
+			}
+		}
+	}
+
+	// TAG[PID]: MESSAGE
+	end := bytes.IndexAny(data, "[: ")
+	if end > 0 && end <= 48 {
+		rest := data[end:]
+		if rest[0] == '[' {
+			if pid := bytes.IndexByte(rest, ']'); pid > 0 {
+				rest = rest[pid+1:]
+			}
+		}
+		if len(rest) > 0 && rest[0] == ':' {
+			msg.Program = string(data[:end])
+			data = bytes.TrimLeft(rest[1:], " ")
+		}
+	}
+
+	msg.Message = data
+}
+
+/*
+* Fields - the fields of message for matching and tags
+*
+* RECEIVER: *SyslogMessage
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   - map[string]string
+ */
+func (msg *SyslogMessage) Fields() map[string]string {
+	return map[string]string{
+		"facility": msg.Facility,
+		"severity": msg.Severity,
+		"hostname": msg.Hostname,
+		"program":  msg.Program,
+	}
+}
+
+/*
+* ListenSyslog - receive syslog messages in a loop
+*
+* PARAMS:
+*   - fa: file agent of syslog input
+*   - finish: a channel to receiver stop signal
+*
+* RETURNS:
+*   No return value
+ */
+func ListenSyslog(fa *FileAgent, finish <-chan bool) {
+	log.Printf("agent for %s is launching...", fa.Name)
+
+	// create one second ticker
+	ticker := time.NewTicker(time.Second * 1)
+	defer ticker.Stop()
+
+	messages := make(chan []byte, SYSLOG_QUEUE_SIZE)
+	done := make(chan bool)
+
+	if err := fa.ListenOpen(messages, done); err != nil {
+		log.Printf("syslog %s listen FAIL: %v", fa.Listen, err)
+	}
+
+LISTEN:
+	for {
+		select {
+		case <-finish:
+			close(done)
+			if fa.Listener != nil {
+				if err := fa.Listener.Close(); err != nil {
+					log.Printf("listener closing FAIL: %v", err)
+				}
+				fa.Listener = nil
+			}
+			break LISTEN
+		case data := <-messages:
+			fa.ProcessSyslog(data)
+		case <-ticker.C:
+			// retry when the address was in use
+			if fa.Listener == nil {
+				if err := fa.ListenOpen(messages, done); err != nil {
+					log.Printf("syslog %s listen FAIL: %v", fa.Listen, err)
+				}
+			}
+			fa.Timeup()
+		}
+	}
+
+	wg.Done()
+	log.Printf("agent for %s is exiting...", fa.Name)
+}
+
+/*
+* ListenOpen - open the syslog listener
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - messages: channel of received messages
+*   - done: a channel closed when agent exits
+*
+* RETURNS:
+*   nil, if succeed
+*   error, if fail
+ */
+func (fa *FileAgent) ListenOpen(messages chan<- []byte, done <-chan bool) error {
+	network, address, err := ParseListen(fa.Listen)
+	if err != nil {
+		return err
+	}
+
+	if network == "udp" {
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return err
+		}
+		fa.Listener = conn
+		go ReceivePackets(conn, messages, done)
+	} else {
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			return err
+		}
+		fa.Listener = listener
+		go AcceptStreams(listener, messages, done)
+	}
+
+	fa.ResetTasks()
+	log.Printf("syslog %s is listening on %s", fa.Name, fa.Listen)
+	return nil
+}
+
+/*
+* ProcessSyslog - parse a syslog message and match it
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - data: one raw message
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) ProcessSyslog(data []byte) {
+	fa.LastData = time.Now().Unix()
+
+	decoded, err := fa.Decode(data)
+	if err != nil {
+		return
+	}
+
+	msg := ParseSyslog(decoded)
+	fa.MatchEntry(msg.Message, msg.Fields(), msg.Time)
+}
+
+/*
+* ReceivePackets - read syslog datagrams until the connection is closed
+*
+* PARAMS:
+*   - conn: udp connection
+*   - messages: channel of received messages
+*   - done: a channel closed when agent exits
+*
+* RETURNS:
+*   No return value
+ */
+func ReceivePackets(conn net.PacketConn, messages chan<- []byte, done <-chan bool) {
+	buf := make([]byte, SYSLOG_MAX_MESSAGE)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-done:
+			default:
+				log.Printf("syslog receiving FAIL: %v", err)
+			}
+			return
+		}
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+
+		select {
+		case messages <- data:
+		case <-done:
+			return
+		}
+	}
+}
+
+/*
+* AcceptStreams - accept syslog connections until the listener is closed
+*
+* PARAMS:
+*   - listener: tcp listener
+*   - messages: channel of received messages
+*   - done: a channel closed when agent exits
+*
+* RETURNS:
+*   No return value
+ */
+func AcceptStreams(listener net.Listener, messages chan<- []byte, done <-chan bool) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-done:
+			default:
+				log.Printf("syslog accepting FAIL: %v", err)
+			}
+			return
+		}
+		go ReceiveStream(conn, messages, done)
+	}
+}
+
+/*
+* ReceiveStream - read framed syslog messages of a tcp connection
+*
+* PARAMS:
+*   - conn: tcp connection
+*   - messages: channel of received messages
+*   - done: a channel closed when agent exits
+*
+* RETURNS:
+*   No return value
+ */
+func ReceiveStream(conn net.Conn, messages chan<- []byte, done <-chan bool) {
+	closed := make(chan bool)
+	defer close(closed)
+
+	// close the connection when agent exits
+	go func() {
+		select {
+		case <-done:
+		case <-closed:
+		}
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), SYSLOG_MAX_MESSAGE)
+	scanner.Split(SplitSyslog)
+
+	for scanner.Scan() {
+		token := scanner.Bytes()
+		if len(token) == 0 {
+			continue
+		}
+
+		data := make([]byte, len(token))
+		copy(data, token)
+
+		select {
+		case messages <- data:
+		case <-done:
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("syslog connection %s FAIL: %v", conn.RemoteAddr(), err)
+	}
+}
+
+/*
+* SplitSyslog - split the stream by octet counting or newline framing (RFC6587)
+*
+* PARAMS:
+*   - data: buffered bytes
+*   - atEOF: true if no more data
+*
+* RETURNS:
+*   - advance, token, error: as bufio.SplitFunc
+ */
+func SplitSyslog(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	// octet counting: MSG-LEN SP SYSLOG-MSG
+	if data[0] >= '1' && data[0] <= '9' {
+		if space := bytes.IndexByte(data, ' '); space > 0 {
+			if size, err := strconv.Atoi(string(data[:space])); err == nil {
+				if size > SYSLOG_MAX_MESSAGE {
+					return 0, nil, fmt.Errorf("message length %d is too large", size)
+				}
+				if len(data) >= space+1+size {
+					return space + 1 + size, data[space+1 : space+1+size], nil
+				}
+				if atEOF {
+					return len(data), data[space+1:], nil
+				}
+				return 0, nil, nil
+			}
+		} else if !atEOF && len(data) < 10 {
+			return 0, nil, nil
+		}
+	}
+
+	// non-transparent framing: messages end with newline
+	if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+		return idx + 1, data[:idx], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
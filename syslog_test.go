@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseSyslogPri(t *testing.T) {
+	cases := []struct {
+		data     string
+		facility string
+		severity string
+		message  string
+	}{
+		{"<0>hello", "kern", "emerg", "hello"},
+		{"<13>hello", "user", "notice", "hello"},
+		{"<191>hello", "local7", "debug", "hello"},
+		// invalid PRI, the message is taken as without PRI
+		{"<-1>hello", "user", "notice", "<-1>hello"},
+		{"<+1>hello", "user", "notice", "<+1>hello"},
+		{"<192>hello", "user", "notice", "<192>hello"},
+		{"<999>hello", "user", "notice", "<999>hello"},
+		{"<1a>hello", "user", "notice", "<1a>hello"},
+		{"<1000>hello", "user", "notice", "<1000>hello"},
+		{"<>hello", "user", "notice", "<>hello"},
+	}
+
+	for _, c := range cases {
+		msg := ParseSyslog([]byte(c.data))
+		if msg.Facility != c.facility || msg.Severity != c.severity {
+			t.Errorf("ParseSyslog(%q): facility %s severity %s, want %s %s",
+				c.data, msg.Facility, msg.Severity, c.facility, c.severity)
+		}
+		if string(msg.Message) != c.message {
+			t.Errorf("ParseSyslog(%q): message %q, want %q", c.data, msg.Message, c.message)
+		}
+	}
+}
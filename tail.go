@@ -6,6 +6,7 @@
 * 2017/8/18, by Ye Zhiqin, create
 * 2017/9/30, by Ye Zhiqin, modify
 * 2018/1/3, by Ye Zhiqin, modify
+* 2026/10/19, modify
 *
 * DESCRIPTION
 * This file contains the definition of file agent
@@ -15,28 +16,60 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"path"
+	"regexp"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"golang.org/x/text/encoding"
 )
 
 type FileAgent struct {
+	Name           string
+	Type           string
 	Filename       string
+	Listen         string
+	Listener       io.Closer
+	Stream         io.Closer
+	Pending        []byte
+	JournalArgs    []string
+	CursorFile     string
+	Cursor         string
+	SavedCursor    string
 	File           *os.File
 	FileInfo       os.FileInfo
 	LastOffset     int64
 	UnchangeTime   int
+	Compressed     bool
 	Delimiter      string
+	Encoding       encoding.Encoding
+	CodeUnit       int
+	Decoder        *encoding.Decoder
+	DecodeFail     int64
 	TsEnabled      bool
 	TsPattern      string
+	TsRe           *regexp.Regexp
+	Lateness       int64
+	Watermark      int64
+	TsUpdate       int64
+	StaleAfter     int64
+	LastData       int64
+	StatusTime     int64
 	InotifyEnabled bool
 	Tasks          []*AgentTask
+	Prefilter      *Prefilter
+	TsFrom         int64
+	TsTo           int64
+	Sink           func(data []*FalconData)
+	Forward        func(line []byte)
+	Containers     map[string]*ContainerFile
+	DumpRequested  int32
 }
 
 type AgentTask struct {
@@ -45,95 +78,316 @@ type AgentTask struct {
 	CounterType string
 	Step        int64
 	Pattern     string
+	Re          *regexp.Regexp
 	Reversed    bool
-	Threshold   float64
+	Filter      *LineFilter
+	Fields      FieldFilter
+	TagFields   []string
+	Series      map[string]*AgentTask
+	Thresholds  []*Threshold
+	Extractor   *ValueExtractor
 	Method      string
-	TsStart     int64
-	TsEnd       int64
-	TsUpdate    int64
-	ValueCnt    int64
-	ValueMax    float64
-	ValueMin    float64
-	ValueSum    float64
+	ZeroFill    bool
+	Windows     []*Window
+	Flushed     int64
+	Active      int64
+	LateCnt     int64
+	Dropped     int64
+	Trace       *TaskTrace
 }
 
 /*
-* Report - push and update data after a period passed
+* NewFileAgent - generate the file agent of a log
 *
-* RECEIVER: *FileAgent
+* PARAMS:
+*   - one: log configuration
+*
+* RETURNS:
+*   - *FileAgent, nil: if succeed
+*   - nil, error: if fail
+ */
+func NewFileAgent(one LogConfig) (*FileAgent, error) {
+	var tasks []*AgentTask
+	for _, item := range one.Items {
+		task, err := NewAgentTask(item)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	log.Printf("tasks: %v", tasks)
+
+	agent := new(FileAgent)
+	agent.Name = one.Name
+	agent.Type = one.Type
+	if agent.Type == "" {
+		agent.Type = LOG_TYPE_FILE
+	}
+	agent.Filename = one.Path
+	agent.Listen = one.Listen
+	agent.Listener = nil
+	agent.JournalArgs = one.JournalArgs
+	agent.CursorFile = one.CursorFile
+	if agent.CursorFile == "" {
+		agent.CursorFile = one.Name + ".cursor"
+	}
+	agent.File = nil
+	agent.FileInfo = nil
+	agent.LastOffset = 0
+	agent.UnchangeTime = 0
+	agent.Delimiter = one.Delimiter
+
+	enc, err := LookupEncoding(one.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	agent.Encoding = enc
+	agent.CodeUnit = CodeUnit(one.Encoding)
+	agent.TsEnabled = one.TsEnabled
+	agent.TsPattern = one.TsPattern
+	agent.Lateness = one.Lateness
+	agent.Watermark = 0
+	agent.TsUpdate = 0
+	agent.StaleAfter = one.StaleAfter
+	if agent.StaleAfter == 0 {
+		agent.StaleAfter = DEFAULT_STALE_AFTER
+	}
+	agent.LastData = 0
+	agent.StatusTime = 0
+	agent.InotifyEnabled = one.InotifyEnabled
+	agent.Tasks = tasks
+	agent.Prefilter = NewPrefilter(tasks)
+
+	// container logs are tagged by container unless the item says otherwise
+	if agent.Type == LOG_TYPE_CONTAINER {
+		for idx, task := range tasks {
+			if one.Items[idx].TagFields == nil {
+				task.TagFields = defaultContainerTagFields
+			}
+		}
+	}
+
+	// without pattern the timestamp carried by the input is used
+	if one.TsEnabled && one.TsPattern != "" {
+		re, err := regexp.Compile(one.TsPattern)
+		if err != nil {
+			return nil, err
+		}
+		agent.TsRe = re
+	}
+
+	return agent, nil
+}
+
+/*
+* NewAgentTask - generate the agent task of an item
 *
 * PARAMS:
-*   - ts: timestamp
-*   - tsEnabled: is log file timestamp enabled
+*   - item: item configuration
 *
 * RETURNS:
-*   No paramter
+*   - *AgentTask, nil: if succeed
+*   - nil, error: if fail
+ */
+func NewAgentTask(item ItemConfig) (*AgentTask, error) {
+	task := new(AgentTask)
+
+	task.Metric = item.Metric
+	task.Tags = item.Tags
+	task.CounterType = item.CounterType
+	task.Step = item.Step
+	task.Pattern = item.Pattern
+	task.Reversed = item.Reversed
+
+	re, err := regexp.Compile(item.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	task.Re = re
+
+	filter, err := NewLineFilter(item.Include, item.IncludeMode, item.Exclude, item.ExcludeMode)
+	if err != nil {
+		return nil, err
+	}
+	task.Filter = filter
+
+	fields, err := NewFieldFilter(item.Match)
+	if err != nil {
+		return nil, err
+	}
+	task.Fields = fields
+	task.TagFields = item.TagFields
+
+	if item.Method != "count" {
+		extractor, err := NewValueExtractor(re, item)
+		if err != nil {
+			return nil, err
+		}
+		task.Extractor = extractor
+	}
+
+	task.Thresholds = NewThresholds(item)
+	task.Method = item.Method
+	task.ZeroFill = true
+	if item.ZeroFill != nil {
+		task.ZeroFill = *item.ZeroFill
+	}
+	task.Reset()
+
+	return task, nil
+}
+
+/*
+* Report - generate the data of a closed window
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   - window: closed window
+*
+* RETURNS:
+*   - []*FalconData: data points of window
  */
-func (task *AgentTask) Report(ts time.Time, timeup bool) {
+func (task *AgentTask) Report(window *Window) []*FalconData {
 	var data []*FalconData
 
+	falcon := CurrentFalcon()
+	ts := window.PushTimestamp(falcon.Timestamp)
+
+	// lines dropped for arriving after their window was closed
+	if task.LateCnt > 0 {
+		metricLate := task.Metric + ".late"
+		point := NewFalconData(metricLate, falcon.Endpoint, task.LateCnt, task.CounterType, task.Tags, ts, task.Step)
+		data = append(data, point)
+		task.LateCnt = 0
+	}
+
+	// nothing matched in the window
+	if window.ValueCnt == 0 && !task.ZeroFill {
+		return data
+	}
+
 	if task.Method == "count" {
 		metricCnt := task.Metric + ".cnt"
-		point := NewFalconData(metricCnt, config.Falcon.Endpoint, task.ValueCnt, task.CounterType, task.Tags, task.TsEnd, task.Step)
+		point := NewFalconData(metricCnt, falcon.Endpoint, window.ValueCnt, task.CounterType, task.Tags, ts, task.Step)
 		data = append(data, point)
 	}
 
 	if task.Method == "Tcount" {
-		metricCnt := task.Metric + ".tcnt"
-		point := NewFalconData(metricCnt, config.Falcon.Endpoint, task.ValueCnt, task.CounterType, task.Tags, task.TsEnd, task.Step)
-		data = append(data, point)
+		for idx, threshold := range task.Thresholds {
+			metricCnt := task.Metric + "." + threshold.Name
+			point := NewFalconData(metricCnt, falcon.Endpoint, window.ThresholdCnt[idx], task.CounterType, task.Tags, ts, task.Step)
+			data = append(data, point)
+		}
 	}
 
 	if task.Method == "statistic" {
 		metricCnt := task.Metric + ".cnt"
-		point := NewFalconData(metricCnt, config.Falcon.Endpoint, task.ValueCnt, task.CounterType, task.Tags, task.TsEnd, task.Step)
+		point := NewFalconData(metricCnt, falcon.Endpoint, window.ValueCnt, task.CounterType, task.Tags, ts, task.Step)
 		data = append(data, point)
 
 		metricMax := task.Metric + ".max"
-		point = NewFalconData(metricMax, config.Falcon.Endpoint, task.ValueMax, task.CounterType, task.Tags, task.TsEnd, task.Step)
+		point = NewFalconData(metricMax, falcon.Endpoint, window.ValueMax, task.CounterType, task.Tags, ts, task.Step)
 		data = append(data, point)
 
 		metricMin := task.Metric + ".min"
-		if task.ValueMin > task.ValueMax {
-			point = NewFalconData(metricMin, config.Falcon.Endpoint, 0, task.CounterType, task.Tags, task.TsEnd, task.Step)
+		if window.ValueMin > window.ValueMax {
+			point = NewFalconData(metricMin, falcon.Endpoint, 0, task.CounterType, task.Tags, ts, task.Step)
 			data = append(data, point)
 		} else {
-			point = NewFalconData(metricMin, config.Falcon.Endpoint, task.ValueMin, task.CounterType, task.Tags, task.TsEnd, task.Step)
+			point = NewFalconData(metricMin, falcon.Endpoint, window.ValueMin, task.CounterType, task.Tags, ts, task.Step)
 			data = append(data, point)
 		}
 
 		metricAvg := task.Metric + ".avg"
-		if task.ValueCnt == 0 {
-			point = NewFalconData(metricAvg, config.Falcon.Endpoint, 0, task.CounterType, task.Tags, task.TsEnd, task.Step)
+		if window.ValueCnt == 0 {
+			point = NewFalconData(metricAvg, falcon.Endpoint, 0, task.CounterType, task.Tags, ts, task.Step)
 			data = append(data, point)
 		} else {
-			point = NewFalconData(metricAvg, config.Falcon.Endpoint, task.ValueSum/float64(task.ValueCnt), task.CounterType, task.Tags, task.TsEnd, task.Step)
+			point = NewFalconData(metricAvg, falcon.Endpoint, window.ValueSum/float64(window.ValueCnt), task.CounterType, task.Tags, ts, task.Step)
 			data = append(data, point)
 		}
 	}
 
+	return data
+}
+
+/*
+* Push - push data to open falcon
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - data: data points
+*
+* RETURNS:
+*   No paramter
+ */
+func (fa *FileAgent) Push(data []*FalconData) {
+	if len(data) == 0 {
+		return
+	}
+
+	if fa.Sink != nil {
+		fa.Sink(data)
+		return
+	}
+
 	log.Printf("falcon point: %v", data)
-	response, err := PushData(config.Falcon.Url, data)
+	response, err := PushData(CurrentFalcon().Url, data)
 	if err != nil {
 		log.Printf("push data to falcon FAIL: %v", err)
+		return
 	}
 	log.Printf("push data to falcon succeed: %s", string(response))
+}
 
-	// update value
-	task.ValueCnt = 0
-	task.ValueMax = 0
-	task.ValueMin = 1 << 32
-	task.ValueSum = 0
-
-	//update timestamp
-	if timeup {
-		task.TsStart += task.Step
-		task.TsEnd += task.Step
-		task.TsUpdate = task.TsEnd - 1
-	} else {
-		task.TsStart += task.Step
-		task.TsEnd += task.Step
-		task.TsUpdate = ts.Unix()
+/*
+* Expire - report the windows ending before the deadline
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - deadline: windows ending before it are closed
+*
+* RETURNS:
+*   No paramter
+ */
+func (fa *FileAgent) Expire(deadline int64) {
+	var data []*FalconData
+	for _, task := range fa.Tasks {
+		for _, serie := range task.AllSeries() {
+			for _, window := range serie.Expire(deadline) {
+				points := serie.Report(window)
+				serie.Trace.Report(window, serie.Tags, points)
+				data = append(data, points...)
+			}
+		}
+	}
+	fa.EvictSeries()
+	fa.Push(data)
+}
+
+/*
+* EvictSeries - remove the idle series of tasks
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No paramter
+ */
+func (fa *FileAgent) EvictSeries() {
+	// idle by the log timestamps when they are used
+	now := time.Now().Unix()
+	if fa.TsEnabled {
+		now = fa.Watermark
+	}
+	for _, task := range fa.Tasks {
+		task.EvictSeries(now)
 	}
 }
 
@@ -149,21 +403,40 @@ func (task *AgentTask) Report(ts time.Time, timeup bool) {
 *   No paramter
  */
 func (fa *FileAgent) Timeup() {
-	ts := time.Now()
+	now := time.Now().Unix()
 
-	for _, task := range fa.Tasks {
-		if fa.TsEnabled {
-			if ts.Unix()-task.TsUpdate >= task.Step {
-				//push data and update task when the time between now and last update time is longer than a step
-				task.Report(ts, true)
-			}
-		} else {
-			if ts.Unix()-task.TsStart >= task.Step {
-				//push data and update task when the time between now and start time is longer than a step
-				task.Report(ts, true)
+	// the state is dumped in the goroutine of agent, never read halfway
+	if atomic.CompareAndSwapInt32(&fa.DumpRequested, 1, 0) {
+		fa.Dump()
+	}
+
+	var data []*FalconData
+	for _, parent := range fa.Tasks {
+		for _, task := range parent.AllSeries() {
+			if fa.TsEnabled {
+				//close all windows when no line comes for a step longer than lateness
+				if now-fa.TsUpdate >= task.Step+fa.Lateness {
+					for _, window := range task.Expire(math.MaxInt64) {
+						data = append(data, task.Report(window)...)
+					}
+				}
+			} else {
+				//close the windows passed and open the current one
+				for _, window := range task.Expire(now) {
+					data = append(data, task.Report(window)...)
+				}
+				task.Locate(now, now, 0)
 			}
 		}
 	}
+	fa.EvictSeries()
+
+	if now-fa.StatusTime >= STATUS_STEP {
+		data = append(data, fa.Status(now)...)
+		fa.StatusTime = now
+	}
+
+	fa.Push(data)
 }
 
 /*
@@ -178,92 +451,180 @@ func (fa *FileAgent) Timeup() {
 *   No paramter
  */
 func (fa *FileAgent) MatchLine(line []byte) {
+	fa.MatchEntry(line, nil, 0)
+}
+
+/*
+* MatchEntry - process each entry of log input
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - line: message of entry
+*   - fields: fields carried by the input, nil for plain lines
+*   - entryTs: timestamp carried by the input, 0 if none
+*
+* RETURNS:
+*   No paramter
+ */
+func (fa *FileAgent) MatchEntry(line []byte, fields map[string]string, entryTs int64) {
+	now := time.Now().Unix()
+	ts := now
 	if fa.TsEnabled {
-		isTsMatched, ts, err := MatchTs(line, fa.TsPattern)
-		if err != nil || !isTsMatched {
+		if fa.TsRe != nil {
+			isTsMatched, logTs, err := MatchTs(line, fa.TsRe)
+			if err != nil || !isTsMatched {
+				return
+			}
+			ts = logTs.Unix()
+		} else if entryTs > 0 {
+			ts = entryTs
+		} else {
 			return
 		}
+		if (fa.TsFrom > 0 && ts < fa.TsFrom) || (fa.TsTo > 0 && ts >= fa.TsTo) {
+			return
+		}
+		if ts > now+MAX_TS_AHEAD {
+			log.Printf("timestamp %d of %s is too far in the future", ts, fa.Filename)
+			return
+		}
+		if ts > fa.Watermark {
+			fa.Watermark = ts
+		}
+		fa.TsUpdate = now
+	}
 
-		for _, task := range fa.Tasks {
-			//push data and update task when the timestamp is not in current period
-			if ts.Unix() > task.TsEnd || ts.Unix() < task.TsStart {
-				log.Printf("timestamp updated!")
-				task.Report(ts, false)
-			}
+	// the regexes of the items are skipped when the line has none of their keywords,
+	// but its timestamp has moved the watermark and the windows behind it are closed
+	if candidates, found := fa.Scan(line); found {
+		fa.MatchTasks(line, fields, ts, candidates)
+	}
 
-			if task.Method == "count" {
-				isKeywordMatched, err := MatchKeyword(line, task.Pattern, task.Reversed)
-				if err != nil || !isKeywordMatched {
-					continue
-				}
-				task.ValueCnt += 1
-				task.TsUpdate = ts.Unix()
-			}
+	if fa.TsEnabled {
+		fa.Expire(fa.Watermark - fa.Lateness)
+	}
+}
 
-			if task.Method == "Tcount" {
-				isCostMatched, cost, err := MatchCost(line, task.Pattern)
-				if err != nil || !isCostMatched {
-					continue
-				}
-				if cost > task.Threshold {
-					task.ValueCnt += 1
-				}
-			}
+/*
+* MatchTasks - match the entry against the candidate tasks
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - line: message of entry
+*   - fields: fields carried by the input, nil for plain lines
+*   - ts: timestamp of entry
+*   - candidates: candidate flag of each task
+*
+* RETURNS:
+*   No paramter
+ */
+func (fa *FileAgent) MatchTasks(line []byte, fields map[string]string, ts int64, candidates []bool) {
+	for idx, task := range fa.Tasks {
+		if !candidates[idx] || !task.Fields.Match(fields) || !task.Filter.Match(line) {
+			continue
+		}
 
-			if task.Method == "statistic" {
-				isCostMatched, cost, err := MatchCost(line, task.Pattern)
-				if err != nil || !isCostMatched {
-					continue
-				}
-				task.ValueCnt += 1
-				if task.ValueMax < cost {
-					task.ValueMax = cost
-				}
-				if task.ValueMin > cost {
-					task.ValueMin = cost
-				}
-				task.ValueSum += cost
-				task.TsUpdate = ts.Unix()
-			}
+		// each tag set of the fields has its own windows
+		if task = task.Serie(fields, ts); task == nil {
+			continue
 		}
-	} else {
-		for _, task := range fa.Tasks {
-			if task.Method == "count" {
-				isKeywordMatched, err := MatchKeyword(line, task.Pattern, task.Reversed)
-				if err != nil || !isKeywordMatched {
-					continue
-				}
-				task.ValueCnt += 1
+
+		if task.Method == "count" {
+			isKeywordMatched := MatchKeyword(line, task.Re, task.Reversed)
+			if !isKeywordMatched {
+				continue
+			}
+			if window := fa.Locate(task, ts); window != nil {
+				window.Count()
+				task.Trace.Hit(0)
 			}
+		}
 
-			if task.Method == "Tcount" {
-				isCostMatched, cost, err := MatchCost(line, task.Pattern)
-				if err != nil || !isCostMatched {
-					continue
-				}
-				if cost > task.Threshold {
-					task.ValueCnt += 1
-				}
+		if task.Method == "Tcount" {
+			isCostMatched, cost, err := MatchCost(line, task.Re, task.Extractor)
+			if err != nil || !isCostMatched {
+				continue
 			}
+			if window := fa.Locate(task, ts); window != nil {
+				window.CountThresholds(task.Thresholds, cost)
+				task.Trace.Hit(cost)
+			}
+		}
 
-			if task.Method == "statistic" {
-				isCostMatched, cost, err := MatchCost(line, task.Pattern)
-				if err != nil || !isCostMatched {
-					continue
-				}
-				task.ValueCnt += 1
-				if task.ValueMax < cost {
-					task.ValueMax = cost
-				}
-				if task.ValueMin > cost {
-					task.ValueMin = cost
-				}
-				task.ValueSum += cost
+		if task.Method == "statistic" {
+			isCostMatched, cost, err := MatchCost(line, task.Re, task.Extractor)
+			if err != nil || !isCostMatched {
+				continue
+			}
+			if window := fa.Locate(task, ts); window != nil {
+				window.Statistic(cost)
+				task.Trace.Hit(cost)
 			}
 		}
 	}
 }
 
+/*
+* Locate - find the window of task for a line
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - task: agent task
+*   - ts: timestamp of line
+*
+* RETURNS:
+*   - *Window: the window of line
+*   - nil: if the line is too late
+ */
+func (fa *FileAgent) Locate(task *AgentTask, ts int64) *Window {
+	if fa.TsEnabled {
+		return task.Locate(ts, fa.Watermark, fa.Lateness)
+	}
+	return task.Locate(ts, ts, 0)
+}
+
+/*
+* ResetTasks - restart the task windows
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No paramter
+ */
+func (fa *FileAgent) ResetTasks() {
+	fa.Watermark = 0
+	fa.TsUpdate = time.Now().Unix()
+	fa.LastData = fa.TsUpdate
+	for _, task := range fa.Tasks {
+		task.Reset()
+	}
+}
+
+/*
+* Scan - find the tasks which may match the line
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - line: a line of log file
+*
+* RETURNS:
+*   - []bool: candidate flag of each task
+*   - bool: true if there is any candidate
+ */
+func (fa *FileAgent) Scan(line []byte) ([]bool, bool) {
+	if fa.Prefilter == nil {
+		fa.Prefilter = NewPrefilter(fa.Tasks)
+	}
+	return fa.Prefilter.Scan(line)
+}
+
 /*
 * ReadRemainder - reading new bytes of log file
 *
@@ -277,6 +638,11 @@ func (fa *FileAgent) MatchLine(line []byte) {
 *   error: fail
  */
 func (fa *FileAgent) ReadRemainder() error {
+	// compressed file is read as a whole when it is opened
+	if fa.Compressed {
+		return nil
+	}
+
 	tailable := fa.FileInfo.Mode().IsRegular()
 	size := fa.FileInfo.Size()
 
@@ -311,12 +677,10 @@ func (fa *FileAgent) ReadRemainder() error {
 		log.Printf("file %s read 0 data", fa.Filename)
 		return nil
 	}
+	fa.LastData = time.Now().Unix()
 
-	if fa.Delimiter == "" {
-		fa.Delimiter = "\n"
-	}
-	sep := []byte(fa.Delimiter)
-	lines := bytes.SplitAfter(data, sep)
+	sep := fa.Separator()
+	lines := SplitLines(data[:readsize], sep, fa.CodeUnit)
 	length := len(lines)
 
 	for idx, line := range lines {
@@ -334,7 +698,7 @@ func (fa *FileAgent) ReadRemainder() error {
 
 			break
 		}
-		fa.MatchLine(line)
+		fa.ProcessLine(line)
 	}
 	return nil
 }
@@ -360,6 +724,7 @@ TAIL:
 	for {
 		select {
 		case <-finish:
+			fa.Drain()
 			if fa.File != nil {
 				if err := fa.File.Close(); err != nil {
 					log.Printf("file closing FAIL: %v", err)
@@ -458,6 +823,7 @@ TRACE:
 			if err := watcher.Remove(dir); err != nil {
 				log.Printf("watcher file removing FAIL: %s", err.Error())
 			}
+			fa.Drain()
 			if fa.File != nil {
 				if err := fa.File.Close(); err != nil {
 					log.Printf("file closing FAIL: %s", err.Error())
@@ -489,6 +855,7 @@ TRACE:
 				// REMOVE/RENAME event
 				if 4 == event.Op || 8 == event.Op {
 					fmt.Printf("fa %s, watch %s receive event REMOVE|RENAME\n", fa.Filename, event.Name)
+					fa.Drain()
 					fa.FileClose()
 				}
 				// CHMOD event
@@ -530,6 +897,7 @@ func (fa *FileAgent) FileOpen() error {
 		}
 	}
 
+	last := fa.FileInfo
 	fa.File = nil
 	fa.FileInfo = nil
 
@@ -548,6 +916,12 @@ func (fa *FileAgent) FileOpen() error {
 		return err
 	}
 
+	// the file inherited from the agent before reload
+	if last != nil && os.SameFile(last, fileinfo) && !IsCompressed(fa.Filename) {
+		fa.FileResume(file, fileinfo)
+		return nil
+	}
+
 	fmt.Printf("file %s is open\n", fa.Filename)
 
 	fa.File = file
@@ -560,30 +934,10 @@ func (fa *FileAgent) FileOpen() error {
 		log.Printf("seek file %s FAIL: %s", fa.Filename, err.Error())
 	}
 	fa.LastOffset += fa.FileInfo.Size()
+	fa.Compressed = IsCompressed(fa.Filename)
 
-	// set timestamp
-	now := time.Now()
-	minute := now.Format("200601021504")
-
-	tsNow := now.Unix()
-	tsStart := tsNow
-
-	start, err := time.ParseInLocation("20060102150405", minute+"00", now.Location())
-	if err != nil {
-		log.Printf("timestamp setting FAIL: %s", err.Error())
-	} else {
-		tsStart = start.Unix()
-	}
-
-	for _, task := range fa.Tasks {
-		task.TsStart = tsStart
-		task.TsEnd = tsStart + task.Step - 1
-		task.TsUpdate = tsNow
-		task.ValueCnt = 0
-		task.ValueMax = 0
-		task.ValueMin = 1 << 32
-		task.ValueSum = 0
-	}
+	// keep the windows, the ones inherited before reload are reported as usual
+	fa.LastData = time.Now().Unix()
 
 	return nil
 }
@@ -639,15 +993,7 @@ func (fa *FileAgent) FileClose() error {
 	fa.LastOffset = 0
 	fa.UnchangeTime = 0
 
-	for _, task := range fa.Tasks {
-		task.TsStart = 0
-		task.TsEnd = 0
-		task.TsUpdate = 0
-		task.ValueCnt = 0
-		task.ValueMax = 0
-		task.ValueMin = 1 << 32
-		task.ValueSum = 0
-	}
+	// keep the windows, they are closed by Timeup while the file is missing
 
 	return nil
 }
@@ -665,7 +1011,8 @@ func (fa *FileAgent) FileClose() error {
 *   error: fail
  */
 func (fa *FileAgent) FileReopen() error {
-	// close old file
+	// read the rest of old file and close it
+	fa.Drain()
 	if fa.File != nil {
 		if err := fa.File.Close(); err != nil {
 			log.Printf("file closing FAIL: %s", err.Error())
@@ -702,29 +1049,11 @@ func (fa *FileAgent) FileReopen() error {
 		log.Printf("seek file %s FAIL: %s", fa.Filename, err.Error())
 	}
 
-	// set timestamp
-	now := time.Now()
-	minute := now.Format("200601021504")
-
-	tsNow := now.Unix()
-	tsStart := tsNow
-
-	start, err := time.ParseInLocation("20060102150405", minute+"00", now.Location())
-	if err != nil {
-		log.Printf("timestamp setting FAIL: %s", err.Error())
-	} else {
-		tsStart = start.Unix()
+	if err := fa.ReadCompressed(); err != nil {
+		log.Printf("compressed file %s reading FAIL: %s", fa.Filename, err.Error())
 	}
 
-	for _, task := range fa.Tasks {
-		task.TsStart = tsStart
-		task.TsEnd = tsStart + task.Step - 1
-		task.TsUpdate = tsNow
-		task.ValueCnt = 0
-		task.ValueMax = 0
-		task.ValueMin = 1 << 32
-		task.ValueSum = 0
-	}
+	fa.LastData = time.Now().Unix()
 
 	return nil
 }
@@ -759,9 +1088,17 @@ func (fa *FileAgent) FileRecheck() error {
 	}
 
 	isSameFile := os.SameFile(fa.FileInfo, fileinfo)
-	if !isSameFile {
+	if isSameFile && fa.File == nil && !IsCompressed(fa.Filename) {
+		// the file inherited from the agent before reload
+		fa.FileResume(file, fileinfo)
+		return nil
+	} else if !isSameFile {
 		log.Printf("file %s recheck, it is a new file", fa.Filename)
-		if fa.File != nil {
+
+		// the file is rotated, read the rest of old file and the new file from start
+		rotated := fa.File != nil
+		if rotated {
+			fa.Drain()
 			if err := fa.File.Close(); err != nil {
 				log.Printf("old file closing FAIL: %v", err)
 			}
@@ -772,44 +1109,127 @@ func (fa *FileAgent) FileRecheck() error {
 		fa.LastOffset = 0
 		fa.UnchangeTime = 0
 
-		// seek the cursor to the end of new file
-		offset, err := fa.File.Seek(fa.FileInfo.Size(), os.SEEK_SET)
-		if err != nil {
-			log.Printf("seek file %s FAIL: %v", fa.Filename, err)
-		}
-		log.Printf("seek file %s to %d", fa.Filename, offset)
-		fa.LastOffset += fa.FileInfo.Size()
-
-		now := time.Now()
-		minute := now.Format("200601021504")
-
-		tsNow := now.Unix()
-		tsStart := tsNow
-
-		start, err := time.ParseInLocation("20060102150405", minute+"00", now.Location())
-		if err != nil {
-			log.Printf("timestamp setting FAIL: %v", err)
+		if rotated {
+			if err := fa.ReadCompressed(); err != nil {
+				log.Printf("compressed file %s reading FAIL: %v", fa.Filename, err)
+			}
+			if err := fa.ReadRemainder(); err != nil {
+				log.Printf("file %s reading FAIL: %v", fa.Filename, err)
+			}
 		} else {
-			tsStart = start.Unix()
+			// seek the cursor to the end of new file
+			offset, err := fa.File.Seek(fa.FileInfo.Size(), os.SEEK_SET)
+			if err != nil {
+				log.Printf("seek file %s FAIL: %v", fa.Filename, err)
+			}
+			log.Printf("seek file %s to %d", fa.Filename, offset)
+			fa.LastOffset += fa.FileInfo.Size()
+			fa.Compressed = IsCompressed(fa.Filename)
 		}
 
-		for _, task := range fa.Tasks {
-			task.TsStart = tsStart
-			task.TsEnd = tsStart + task.Step - 1
-			task.TsUpdate = tsNow
-			task.ValueCnt = 0
-			task.ValueMax = 0
-			task.ValueMin = 1 << 32
-			task.ValueSum = 0
-		}
+		fa.LastData = time.Now().Unix()
 
 		return nil
 	} else {
+		if err := file.Close(); err != nil {
+			log.Printf("file closing FAIL: %v", err)
+		}
 		fa.UnchangeTime = 0
 		return nil
 	}
 }
 
+/*
+* FileResume - read on from the offset inherited from the agent before reload
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   - file: the file opened again
+*   - fileinfo: stat of the file
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) FileResume(file *os.File, fileinfo os.FileInfo) {
+	// the file is truncated since then
+	offset := fa.LastOffset
+	if offset > fileinfo.Size() {
+		offset = 0
+	}
+
+	if _, err := file.Seek(offset, os.SEEK_SET); err != nil {
+		log.Printf("seek file %s FAIL: %v", fa.Filename, err)
+	}
+	log.Printf("file %s is resumed at %d", fa.Filename, offset)
+
+	fa.File = file
+	fa.FileInfo = fileinfo
+	fa.LastOffset = offset
+	fa.UnchangeTime = 0
+	fa.Compressed = false
+}
+
+/*
+* Drain - read the rest of current file before it is closed
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (fa *FileAgent) Drain() {
+	if fa.File == nil || fa.Compressed {
+		return
+	}
+
+	// the file may be renamed or removed, stat the descriptor
+	fileinfo, err := fa.File.Stat()
+	if err != nil {
+		log.Printf("file %s stat FAIL: %v", fa.Filename, err)
+		return
+	}
+	fa.FileInfo = fileinfo
+
+	if err := fa.ReadRemainder(); err != nil {
+		log.Printf("file %s draining FAIL: %v", fa.Filename, err)
+	}
+}
+
+/*
+* ReadCompressed - read the whole content of a compressed file
+*
+* RECEIVER: *FileAgent
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   nil, if succeed or the file is not compressed
+*   error, if fail
+ */
+func (fa *FileAgent) ReadCompressed() error {
+	fa.Compressed = IsCompressed(fa.Filename)
+	if !fa.Compressed {
+		return nil
+	}
+
+	// the content is read once, growth of the file is not tailed
+	fa.LastOffset = fa.FileInfo.Size()
+
+	reader, err := OpenLog(fa.Filename)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	log.Printf("file %s is compressed, read it as a whole", fa.Filename)
+	return ReadLines(reader, string(fa.Separator()), fa.CodeUnit, fa.ProcessLine)
+}
+
 /*
 * IsChanged - check the change of log file
 *
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileOpenKeepsWindows(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	dir, err := ioutil.TempDir("", "log-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "test.log")
+	if err := ioutil.WriteFile(filename, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	agent, err := NewFileAgent(LogConfig{
+		Name:  "test",
+		Path:  filename,
+		Items: []ItemConfig{{Metric: "error", Pattern: "ERROR", Method: "count", Step: 60, CounterType: "GAUGE"}},
+	})
+	if err != nil {
+		t.Fatalf("NewFileAgent: %v", err)
+	}
+
+	// a window inherited before reload, the file is rotated meanwhile
+	agent.Tasks[0].Locate(1000, 1000, 0).Count()
+	if err := agent.FileOpen(); err != nil {
+		t.Fatalf("FileOpen: %v", err)
+	}
+	defer agent.File.Close()
+
+	windows := agent.Tasks[0].Windows
+	if len(windows) != 1 || windows[0].ValueCnt != 1 {
+		t.Fatalf("windows are dropped on open: %v", windows)
+	}
+	if agent.LastOffset != 4 {
+		t.Fatalf("offset %d, want the end of file", agent.LastOffset)
+	}
+}
+
+func TestGapLimit(t *testing.T) {
+	task, err := NewAgentTask(ItemConfig{Metric: "error", Pattern: "ERROR", Method: "count", Step: 60, CounterType: "GAUGE"})
+	if err != nil {
+		t.Fatalf("NewAgentTask: %v", err)
+	}
+
+	windows := task.Gap(0, 600)
+	if len(windows) != 10 || windows[0].TsStart != 0 || windows[9].TsStart != 540 {
+		t.Fatalf("gap of 10 windows: %d windows", len(windows))
+	}
+
+	// only the latest windows of a long gap are filled
+	windows = task.Gap(0, 86400)
+	if len(windows) != MAX_ZERO_FILL || windows[len(windows)-1].TsStart != 86400-60 {
+		t.Fatalf("gap of a day: %d windows", len(windows))
+	}
+}
+
+const testTsPattern = `(\d{4})-(\d{2})-(\d{2}) (\d{2}):(\d{2}):(\d{2})`
+
+func newTsAgent(t *testing.T, items []ItemConfig, pushed *[]*FalconData) *FileAgent {
+	log.SetOutput(ioutil.Discard)
+	config = &Config{Falcon: FalconConfig{Timestamp: TIMESTAMP_AT_START}}
+
+	for idx := range items {
+		items[idx].Step = 60
+		items[idx].CounterType = "GAUGE"
+	}
+	agent, err := NewFileAgent(LogConfig{Name: "test", Path: "test.log", TsEnabled: true, TsPattern: testTsPattern, Items: items})
+	if err != nil {
+		t.Fatalf("NewFileAgent: %v", err)
+	}
+	agent.Sink = func(data []*FalconData) {
+		*pushed = append(*pushed, data...)
+	}
+	return agent
+}
+
+func tsLine(ts int64, text string) []byte {
+	return []byte(time.Unix(ts, 0).Format("2006-01-02 15:04:05") + " " + text)
+}
+
+func TestExpireWithoutCandidates(t *testing.T) {
+	defer func(saved *Config) { config = saved }(config)
+	var pushed []*FalconData
+	agent := newTsAgent(t, []ItemConfig{{Metric: "error", Pattern: "ERROR", Method: "count"}}, &pushed)
+
+	start := time.Now().Add(-24*time.Hour).Unix() / 60 * 60
+	agent.MatchLine(tsLine(start+5, "ERROR failed"))
+	// hours of lines none of the items looks at
+	for ts := start + 10; ts < start+3*3600; ts += 10 {
+		agent.MatchLine(tsLine(ts, "INFO ok"))
+	}
+
+	for _, point := range pushed {
+		if point.Metric == "error.cnt" && point.Timestamp == start {
+			if point.Value != int64(1) {
+				t.Fatalf("error window = %v, want 1", point.Value)
+			}
+			return
+		}
+	}
+	t.Fatalf("error window is not pushed, %d points pushed", len(pushed))
+}
+
+func TestZeroFillSilentItem(t *testing.T) {
+	defer func(saved *Config) { config = saved }(config)
+	var pushed []*FalconData
+	agent := newTsAgent(t, []ItemConfig{
+		{Metric: "error", Pattern: "ERROR", Method: "count"},
+		{Metric: "info", Pattern: "INFO", Method: "count"},
+	}, &pushed)
+
+	start := time.Now().Add(-24*time.Hour).Unix() / 60 * 60
+	end := start + 2*3600
+	agent.MatchLine(tsLine(start+5, "ERROR failed"))
+	// the error item goes silent, the log keeps busy
+	for ts := start + 10; ts < end; ts += 10 {
+		agent.MatchLine(tsLine(ts, "INFO ok"))
+	}
+
+	values := make(map[int64]interface{})
+	for _, point := range pushed {
+		if point.Metric == "error.cnt" {
+			values[point.Timestamp] = point.Value
+		}
+	}
+	if values[start] != int64(1) {
+		t.Fatalf("error window with data = %v", values[start])
+	}
+	// every window closed by the watermark is reported, not only the latest ones
+	for ts := start + 60; ts < end-60; ts += 60 {
+		if value, ok := values[ts]; !ok || value != int64(0) {
+			t.Fatalf("empty error window at %d = %v, %v", ts-start, value, ok)
+		}
+	}
+}
+
+func TestReportEmptyStatistic(t *testing.T) {
+	defer func(saved *Config) { config = saved }(config)
+	config = &Config{Falcon: FalconConfig{Timestamp: TIMESTAMP_AT_START}}
+
+	off := false
+	for _, zeroFill := range []*bool{nil, &off} {
+		task, err := NewAgentTask(ItemConfig{Metric: "cost", Pattern: `cost=(\d+)`, Method: "statistic", Step: 60, CounterType: "GAUGE", ZeroFill: zeroFill})
+		if err != nil {
+			t.Fatalf("NewAgentTask: %v", err)
+		}
+
+		data := task.Report(NewWindow(600, 60, 0))
+		if zeroFill != nil {
+			if len(data) != 0 {
+				t.Errorf("empty window with zeroFill false: %v", data)
+			}
+			continue
+		}
+
+		// the same points as a window with data, all 0
+		var metrics []string
+		for _, point := range data {
+			metrics = append(metrics, point.Metric)
+			if fmt.Sprint(point.Value) != "0" {
+				t.Errorf("%s of empty window = %v", point.Metric, point.Value)
+			}
+		}
+		if !reflect.DeepEqual(metrics, []string{"cost.cnt", "cost.max", "cost.min", "cost.avg"}) {
+			t.Errorf("metrics of empty window = %v", metrics)
+		}
+	}
+}
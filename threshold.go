@@ -0,0 +1,147 @@
+/*
+* threshold.go - the comparison of cost value for Tcount method
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the definition of threshold and the functions
+* to check the threshold configuration and compare the cost value
+ */
+
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	OP_GT      = ">"
+	OP_GE      = ">="
+	OP_LT      = "<"
+	OP_LE      = "<="
+	OP_EQ      = "=="
+	OP_NE      = "!="
+	OP_BETWEEN = "between"
+
+	// metric suffix of the single threshold item
+	DEFAULT_THRESHOLD_NAME = "tcnt"
+)
+
+type Threshold struct {
+	Name     string
+	Operator string
+	Value    float64
+	Lower    float64
+	Upper    float64
+}
+
+/*
+* NewThresholds - generate thresholds of an item
+*
+* PARAMS:
+*   - item: item configuration
+*
+* RETURNS:
+*   - []*Threshold: thresholds list, the single threshold of item
+*     is used when the thresholds list is not configured
+ */
+func NewThresholds(item ItemConfig) []*Threshold {
+	var thresholds []*Threshold
+
+	if len(item.Thresholds) == 0 {
+		operator := item.Operator
+		if operator == "" {
+			operator = OP_GT
+		}
+		threshold := &Threshold{
+			Name:     DEFAULT_THRESHOLD_NAME,
+			Operator: operator,
+			Value:    item.Threshold,
+		}
+		return append(thresholds, threshold)
+	}
+
+	for _, one := range item.Thresholds {
+		operator := one.Operator
+		if operator == "" {
+			operator = OP_GT
+		}
+		threshold := &Threshold{
+			Name:     one.Name,
+			Operator: operator,
+			Value:    one.Value,
+			Lower:    one.Lower,
+			Upper:    one.Upper,
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds
+}
+
+/*
+* CheckThresholds - check the thresholds configuration of an item
+*
+* PARAMS:
+*   - item: item configuration
+*
+* RETURNS:
+*   - nil: if thresholds are valid
+*   - error: if any threshold is invalid
+ */
+func CheckThresholds(item ItemConfig) error {
+	names := make(map[string]bool)
+	for _, threshold := range NewThresholds(item) {
+		if threshold.Name == "" {
+			return fmt.Errorf("Name of threshold should not EMPTY!")
+		}
+		if names[threshold.Name] {
+			return fmt.Errorf("Name of threshold %s is duplicated", threshold.Name)
+		}
+		names[threshold.Name] = true
+
+		switch threshold.Operator {
+		case OP_GT, OP_GE, OP_LT, OP_LE, OP_EQ, OP_NE:
+		case OP_BETWEEN:
+			if threshold.Lower >= threshold.Upper {
+				return fmt.Errorf("Lower of threshold %s should be less than upper", threshold.Name)
+			}
+		default:
+			return fmt.Errorf("Operator of threshold %s should be '>'/'>='/'<'/'<='/'=='/'!='/'between'", threshold.Name)
+		}
+	}
+	return nil
+}
+
+/*
+* Match - compare the value with threshold
+*
+* RECEIVER: *Threshold
+*
+* PARAMS:
+*   - v: cost value
+*
+* RETURNS:
+*   - true: if the value hits the threshold
+*   - false: if not
+ */
+func (threshold *Threshold) Match(v float64) bool {
+	switch threshold.Operator {
+	case OP_GT:
+		return v > threshold.Value
+	case OP_GE:
+		return v >= threshold.Value
+	case OP_LT:
+		return v < threshold.Value
+	case OP_LE:
+		return v <= threshold.Value
+	case OP_EQ:
+		return v == threshold.Value
+	case OP_NE:
+		return v != threshold.Value
+	case OP_BETWEEN:
+		return v >= threshold.Lower && v < threshold.Upper
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestThresholdMatch(t *testing.T) {
+	cases := []struct {
+		threshold Threshold
+		v         float64
+		match     bool
+	}{
+		// open-ended ranges, the bound is included only by >= and <=
+		{Threshold{Operator: OP_GT, Value: 100}, 100, false},
+		{Threshold{Operator: OP_GT, Value: 100}, 100.5, true},
+		{Threshold{Operator: OP_GE, Value: 100}, 100, true},
+		{Threshold{Operator: OP_GE, Value: 100}, 99.9, false},
+		{Threshold{Operator: OP_LT, Value: 100}, 100, false},
+		{Threshold{Operator: OP_LT, Value: 100}, -1e9, true},
+		{Threshold{Operator: OP_LE, Value: 100}, 100, true},
+		{Threshold{Operator: OP_LE, Value: 100}, 100.1, false},
+		{Threshold{Operator: OP_EQ, Value: 0}, 0, true},
+		{Threshold{Operator: OP_EQ, Value: 0}, 0.1, false},
+		{Threshold{Operator: OP_NE, Value: 0}, 0, false},
+		{Threshold{Operator: OP_NE, Value: 0}, -0.1, true},
+		// between includes the lower bound and excludes the upper one
+		{Threshold{Operator: OP_BETWEEN, Lower: 100, Upper: 500}, 100, true},
+		{Threshold{Operator: OP_BETWEEN, Lower: 100, Upper: 500}, 499.9, true},
+		{Threshold{Operator: OP_BETWEEN, Lower: 100, Upper: 500}, 500, false},
+		{Threshold{Operator: OP_BETWEEN, Lower: 100, Upper: 500}, 99.9, false},
+		{Threshold{Operator: "=>", Value: 100}, 200, false},
+	}
+
+	for _, c := range cases {
+		if match := c.threshold.Match(c.v); match != c.match {
+			t.Errorf("%s %v %v/%v: Match(%v) = %v", c.threshold.Operator, c.threshold.Value,
+				c.threshold.Lower, c.threshold.Upper, c.v, match)
+		}
+	}
+}
+
+func TestThresholdsAdjacent(t *testing.T) {
+	item := ItemConfig{Thresholds: []ThresholdConfig{
+		{Name: "fast", Operator: OP_LT, Value: 100},
+		{Name: "normal", Operator: OP_BETWEEN, Lower: 100, Upper: 500},
+		{Name: "slow", Operator: OP_GE, Value: 500},
+	}}
+	if err := CheckThresholds(item); err != nil {
+		t.Fatalf("CheckThresholds: %v", err)
+	}
+
+	// each value hits exactly one of the adjacent ranges
+	thresholds := NewThresholds(item)
+	for _, v := range []float64{0, 99.9, 100, 499.9, 500, 1e9} {
+		hits := 0
+		for _, threshold := range thresholds {
+			if threshold.Match(v) {
+				hits++
+			}
+		}
+		if hits != 1 {
+			t.Errorf("%v hits %d thresholds", v, hits)
+		}
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	cases := []struct {
+		item  ItemConfig
+		valid bool
+	}{
+		// the single threshold of item, '>' by default
+		{ItemConfig{Threshold: 100}, true},
+		{ItemConfig{Operator: OP_LE, Threshold: 100}, true},
+		{ItemConfig{Operator: "=>", Threshold: 100}, false},
+		{ItemConfig{Thresholds: []ThresholdConfig{{Name: "slow", Value: 500}}}, true},
+		{ItemConfig{Thresholds: []ThresholdConfig{{Name: "slow", Operator: "gt", Value: 500}}}, false},
+		{ItemConfig{Thresholds: []ThresholdConfig{{Value: 500}}}, false},
+		{ItemConfig{Thresholds: []ThresholdConfig{{Name: "slow", Value: 500}, {Name: "slow", Value: 1000}}}, false},
+		{ItemConfig{Thresholds: []ThresholdConfig{{Name: "mid", Operator: OP_BETWEEN, Lower: 100, Upper: 500}}}, true},
+		{ItemConfig{Thresholds: []ThresholdConfig{{Name: "mid", Operator: OP_BETWEEN, Lower: 500, Upper: 500}}}, false},
+		{ItemConfig{Thresholds: []ThresholdConfig{{Name: "mid", Operator: OP_BETWEEN, Lower: 500, Upper: 100}}}, false},
+	}
+
+	for idx, c := range cases {
+		if err := CheckThresholds(c.item); (err == nil) != c.valid {
+			t.Errorf("case %d: CheckThresholds = %v, want valid %v", idx, err, c.valid)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+/*
+* transform.go - the transformation of extracted cost value
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the definition of value transformation and the
+* functions to parse value with unit, convert it to the target unit,
+* and apply scale/offset and an optional arithmetic expression
+* the unit names are case sensitive, 'm' is minute and 'M' is MiB
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Transform struct {
+	Unit       string
+	SourceUnit string
+	Scale      float64
+	Offset     float64
+	Expr       *Expr
+}
+
+type Unit struct {
+	Dimension string
+	Factor    float64
+}
+
+// units and their factor to the base unit of dimension, matched case sensitively
+var units = map[string]Unit{
+	"ns":  {"time", 1e-9},
+	"us":  {"time", 1e-6},
+	"µs":  {"time", 1e-6},
+	"ms":  {"time", 1e-3},
+	"s":   {"time", 1},
+	"sec": {"time", 1},
+	"m":   {"time", 60},
+	"min": {"time", 60},
+	"h":   {"time", 3600},
+	"B":   {"size", 1},
+	"K":   {"size", 1 << 10},
+	"KB":  {"size", 1 << 10},
+	"kB":  {"size", 1 << 10},
+	"KiB": {"size", 1 << 10},
+	"M":   {"size", 1 << 20},
+	"MB":  {"size", 1 << 20},
+	"MiB": {"size", 1 << 20},
+	"G":   {"size", 1 << 30},
+	"GB":  {"size", 1 << 30},
+	"GiB": {"size", 1 << 30},
+	"T":   {"size", 1 << 40},
+	"TB":  {"size", 1 << 40},
+	"TiB": {"size", 1 << 40},
+}
+
+var valueRe = regexp.MustCompile(`^([-+]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][-+]?[0-9]+)?)\s*([a-zA-Zµ]*)$`)
+
+/*
+* NewTransform - generate the value transformation of an item
+*
+* PARAMS:
+*   - cfg: transform configuration
+*
+* RETURNS:
+*   - *Transform, nil: if succeed
+*   - nil, error: if fail
+ */
+func NewTransform(cfg TransformConfig) (*Transform, error) {
+	transform := new(Transform)
+
+	transform.Unit = cfg.Unit
+	transform.SourceUnit = cfg.SourceUnit
+	transform.Scale = cfg.Scale
+	transform.Offset = cfg.Offset
+
+	if transform.Unit != "" {
+		if _, ok := units[transform.Unit]; !ok {
+			return nil, fmt.Errorf("unit %s of transform is unknown", cfg.Unit)
+		}
+	}
+
+	if transform.SourceUnit != "" {
+		source, ok := units[transform.SourceUnit]
+		if !ok {
+			return nil, fmt.Errorf("sourceUnit %s of transform is unknown", cfg.SourceUnit)
+		}
+		if transform.Unit == "" || units[transform.Unit].Dimension != source.Dimension {
+			return nil, fmt.Errorf("sourceUnit %s of transform should convert to a unit of the same kind", cfg.SourceUnit)
+		}
+	}
+
+	// scale 0 makes no sense, treat it as not configured
+	if transform.Scale == 0 {
+		transform.Scale = 1
+	}
+
+	if cfg.Expr != "" {
+		expr, err := ParseExpr(cfg.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("expr %s of transform parsing FAIL: %v", cfg.Expr, err)
+		}
+		transform.Expr = expr
+	}
+
+	return transform, nil
+}
+
+/*
+* Parse - parse the extracted string to value in target unit
+*
+* RECEIVER: *Transform
+*
+* PARAMS:
+*   - raw: extracted string, such as '12ms', '1.5s', '3.2KB' or '1,024'
+*
+* RETURNS:
+*   - value, nil: if succeed
+*   - 0, error: if fail
+ */
+func (transform *Transform) Parse(raw []byte) (float64, error) {
+	s := strings.TrimSpace(string(raw))
+	s = strings.Replace(s, ",", "", -1)
+
+	matches := valueRe.FindStringSubmatch(s)
+	if matches == nil {
+		return 0, fmt.Errorf("value %q is not a number", string(raw))
+	}
+
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	unit := matches[2]
+	if unit == "" {
+		unit = transform.SourceUnit
+	}
+	if unit == "" {
+		return value, nil
+	}
+
+	if transform.Unit == "" {
+		return 0, fmt.Errorf("value %q has unit but the target unit is not configured", string(raw))
+	}
+
+	from, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("unit of value %q is unknown", string(raw))
+	}
+	to := units[transform.Unit]
+	if from.Dimension != to.Dimension {
+		return 0, fmt.Errorf("unit of value %q can not convert to %s", string(raw), transform.Unit)
+	}
+
+	return value * from.Factor / to.Factor, nil
+}
+
+/*
+* Apply - apply scale/offset and expression to the value
+*
+* RECEIVER: *Transform
+*
+* PARAMS:
+*   - value: value in target unit
+*
+* RETURNS:
+*   - value, nil: value after transformation if succeed
+*   - 0, error: if the expression can not be evaluated
+ */
+func (transform *Transform) Apply(value float64) (float64, error) {
+	value = value*transform.Scale + transform.Offset
+	if transform.Expr != nil {
+		return transform.Expr.Eval(value)
+	}
+	return value, nil
+}
+
+/*
+* Value - parse and transform the extracted string
+*
+* RECEIVER: *Transform
+*
+* PARAMS:
+*   - raw: extracted string
+*
+* RETURNS:
+*   - value, nil: if succeed
+*   - 0, error: if fail
+ */
+func (transform *Transform) Value(raw []byte) (float64, error) {
+	value, err := transform.Parse(raw)
+	if err != nil {
+		return 0, err
+	}
+	return transform.Apply(value)
+}
+
+// Expr is the syntax tree of an arithmetic expression on variable x
+type Expr struct {
+	Op    byte
+	Value float64
+	Left  *Expr
+	Right *Expr
+}
+
+type exprParser struct {
+	s   string
+	pos int
+}
+
+/*
+* ParseExpr - parse an arithmetic expression such as '(x - 3) * 1000'
+*
+* PARAMS:
+*   - s: expression with variable x, numbers, + - * / % and parentheses
+*
+* RETURNS:
+*   - *Expr, nil: if succeed
+*   - nil, error: if fail
+ */
+func ParseExpr(s string) (*Expr, error) {
+	p := &exprParser{s: s}
+	expr, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.s[p.pos], p.pos)
+	}
+	return expr, nil
+}
+
+/*
+* Eval - evaluate the expression
+*
+* RECEIVER: *Expr
+*
+* PARAMS:
+*   - x: value of variable x
+*
+* RETURNS:
+*   - result, nil: if succeed
+*   - 0, error: if divided by zero, the value should be dropped
+ */
+func (expr *Expr) Eval(x float64) (float64, error) {
+	switch expr.Op {
+	case 'n':
+		return expr.Value, nil
+	case 'x':
+		return x, nil
+	}
+
+	left, err := expr.Left.Eval(x)
+	if err != nil {
+		return 0, err
+	}
+	if expr.Op == '~' {
+		return -left, nil
+	}
+	right, err := expr.Right.Eval(x)
+	if err != nil {
+		return 0, err
+	}
+
+	switch expr.Op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero when x is %v", x)
+		}
+		return left / right, nil
+	case '%':
+		if int64(right) == 0 {
+			return 0, fmt.Errorf("modulo by zero when x is %v", x)
+		}
+		return float64(int64(left) % int64(right)), nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", expr.Op)
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) parseSum() (*Expr, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) || (p.s[p.pos] != '+' && p.s[p.pos] != '-') {
+			return left, nil
+		}
+		op := p.s[p.pos]
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = &Expr{Op: op, Left: left, Right: right}
+	}
+}
+
+func (p *exprParser) parseProduct() (*Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) || (p.s[p.pos] != '*' && p.s[p.pos] != '/' && p.s[p.pos] != '%') {
+			return left, nil
+		}
+		op := p.s[p.pos]
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Expr{Op: op, Left: left, Right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (*Expr, error) {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '-' {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Op: '~', Left: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (*Expr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	c := p.s[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		expr, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return nil, fmt.Errorf("missing ')' at %d", p.pos)
+		}
+		p.pos++
+		return expr, nil
+	case c == 'x':
+		p.pos++
+		return &Expr{Op: 'x'}, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '.' || (p.s[p.pos] >= '0' && p.s[p.pos] <= '9')) {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Op: 'n', Value: value}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", c, p.pos)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"regexp"
+	"testing"
+)
+
+func TestTransformUnitCase(t *testing.T) {
+	cases := []struct {
+		unit  string
+		raw   string
+		value float64
+		err   bool
+	}{
+		{"s", "2m", 120, false},
+		{"s", "1500ms", 1.5, false},
+		{"B", "2M", 2 << 20, false},
+		{"B", "1MiB", 1 << 20, false},
+		{"KB", "3kB", 3, false},
+		{"MB", "512K", 0.5, false},
+		// 'M' is MiB, it can not convert to seconds
+		{"s", "1M", 0, true},
+		{"s", "1MS", 0, true},
+		{"B", "1kb", 0, true},
+		{"B", "1mb", 0, true},
+	}
+
+	for _, c := range cases {
+		transform, err := NewTransform(TransformConfig{Unit: c.unit})
+		if err != nil {
+			t.Fatalf("NewTransform(%s): %v", c.unit, err)
+		}
+		value, err := transform.Value([]byte(c.raw))
+		if (err != nil) != c.err || value != c.value {
+			t.Errorf("Value(%q) to %s = %v, %v, want %v", c.raw, c.unit, value, err, c.value)
+		}
+	}
+
+	for _, unit := range []string{"MS", "kb", "Mb", "S"} {
+		if _, err := NewTransform(TransformConfig{Unit: unit}); err == nil {
+			t.Errorf("unit %s is accepted", unit)
+		}
+	}
+	if _, err := NewTransform(TransformConfig{Unit: "s", SourceUnit: "M"}); err == nil {
+		t.Errorf("sourceUnit M is accepted for unit s")
+	}
+}
+
+func TestExprDivisionByZero(t *testing.T) {
+	cases := []struct {
+		expr  string
+		x     float64
+		value float64
+		err   bool
+	}{
+		{"x / 2", 3, 1.5, false},
+		{"100 / x", 4, 25, false},
+		{"100 / x", 0, 0, true},
+		{"-(1 / (x - 1))", 1, 0, true},
+		{"x % 3", 7, 1, false},
+		{"x % 0.5", 7, 0, true},
+		{"(x - 3) * 1000", 3.5, 500, false},
+	}
+
+	for _, c := range cases {
+		expr, err := ParseExpr(c.expr)
+		if err != nil {
+			t.Fatalf("ParseExpr(%s): %v", c.expr, err)
+		}
+		value, err := expr.Eval(c.x)
+		if (err != nil) != c.err || value != c.value {
+			t.Errorf("%s when x is %v = %v, %v, want %v", c.expr, c.x, value, err, c.value)
+		}
+	}
+}
+
+func TestMatchCostDropsDivisionByZero(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	re := regexp.MustCompile(`qps=(\d+)`)
+	extractor, err := NewValueExtractor(re, ItemConfig{Metric: "latency", Transform: TransformConfig{Expr: "1000 / x"}})
+	if err != nil {
+		t.Fatalf("NewValueExtractor: %v", err)
+	}
+
+	if matched, value, err := MatchCost([]byte("qps=4"), re, extractor); !matched || err != nil || value != 250 {
+		t.Errorf("MatchCost qps=4 = %v, %v, %v", matched, value, err)
+	}
+	// the value is dropped instead of reported as 0
+	if _, _, err := MatchCost([]byte("qps=0"), re, extractor); err == nil {
+		t.Errorf("MatchCost qps=0 has no error")
+	}
+}
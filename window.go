@@ -0,0 +1,291 @@
+/*
+* window.go - the period windows of agent task
+*
+* history
+* --------------------
+* 2026/10/19, create
+*
+* DESCRIPTION
+* This file contains the definition of period window and the functions
+* to locate the window of a timestamp, aggregate values into it and
+* expire the windows which can not receive data any more
+ */
+
+package main
+
+import (
+	"log"
+	"math"
+	"sort"
+)
+
+const (
+	// timestamps too far in the future are taken as broken and ignored
+	MAX_TS_AHEAD = 86400
+
+	// at most so many empty windows are filled for a gap, the gap of a quiet
+	// log with timestamps is filled at once when its next line comes
+	MAX_ZERO_FILL = 60
+
+	TIMESTAMP_AT_START = "start"
+	TIMESTAMP_AT_END   = "end"
+)
+
+type Window struct {
+	TsStart      int64
+	TsEnd        int64
+	ValueCnt     int64
+	ValueMax     float64
+	ValueMin     float64
+	ValueSum     float64
+	ThresholdCnt []int64
+}
+
+/*
+* NewWindow - generate an empty window
+*
+* PARAMS:
+*   - start: start timestamp of window
+*   - step: length of window
+*   - thresholds: number of thresholds of task
+*
+* RETURNS:
+*   - *Window
+ */
+func NewWindow(start int64, step int64, thresholds int) *Window {
+	window := &Window{
+		TsStart:      start,
+		TsEnd:        start + step - 1,
+		ValueCnt:     0,
+		ValueMax:     0,
+		ValueMin:     1 << 32,
+		ValueSum:     0,
+		ThresholdCnt: make([]int64, thresholds),
+	}
+	return window
+}
+
+/*
+* Count - count a matched line
+*
+* RECEIVER: *Window
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (window *Window) Count() {
+	window.ValueCnt += 1
+}
+
+/*
+* CountThresholds - count the cost value hitting each threshold
+*
+* RECEIVER: *Window
+*
+* PARAMS:
+*   - thresholds: thresholds of task
+*   - cost: cost value
+*
+* RETURNS:
+*   No return value
+ */
+func (window *Window) CountThresholds(thresholds []*Threshold, cost float64) {
+	window.ValueCnt += 1
+	for idx, threshold := range thresholds {
+		if threshold.Match(cost) {
+			window.ThresholdCnt[idx] += 1
+		}
+	}
+}
+
+/*
+* Statistic - aggregate the cost value
+*
+* RECEIVER: *Window
+*
+* PARAMS:
+*   - cost: cost value
+*
+* RETURNS:
+*   No return value
+ */
+func (window *Window) Statistic(cost float64) {
+	window.ValueCnt += 1
+	if window.ValueMax < cost {
+		window.ValueMax = cost
+	}
+	if window.ValueMin > cost {
+		window.ValueMin = cost
+	}
+	window.ValueSum += cost
+}
+
+/*
+* WindowStart - calculate the start of the window containing timestamp
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   - ts: timestamp
+*
+* RETURNS:
+*   - start timestamp of window, aligned to the epoch multiple of step
+ */
+func (task *AgentTask) WindowStart(ts int64) int64 {
+	if task.Step <= 0 {
+		return ts
+	}
+	return ts - ts%task.Step
+}
+
+/*
+* PushTimestamp - the timestamp of data points of a window
+*
+* RECEIVER: *Window
+*
+* PARAMS:
+*   - at: 'start' or 'end' of window
+*
+* RETURNS:
+*   - timestamp
+ */
+func (window *Window) PushTimestamp(at string) int64 {
+	if at == TIMESTAMP_AT_START {
+		return window.TsStart
+	}
+	return window.TsEnd
+}
+
+/*
+* Locate - find or open the window containing timestamp
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   - ts: timestamp of line
+*   - watermark: the latest timestamp seen in log
+*   - lateness: seconds a window stays open after its end
+*
+* RETURNS:
+*   - *Window: the window of timestamp
+*   - nil: if the line is too late and dropped
+ */
+func (task *AgentTask) Locate(ts int64, watermark int64, lateness int64) *Window {
+	start := task.WindowStart(ts)
+	end := start + task.Step - 1
+
+	if end+lateness < watermark || end <= task.Flushed {
+		task.LateCnt += 1
+		return nil
+	}
+
+	idx := sort.Search(len(task.Windows), func(i int) bool {
+		return task.Windows[i].TsStart >= start
+	})
+	if idx < len(task.Windows) && task.Windows[idx].TsStart == start {
+		return task.Windows[idx]
+	}
+
+	// jump to the window directly, the windows between are not opened
+	window := NewWindow(start, task.Step, len(task.Thresholds))
+	task.Windows = append(task.Windows, nil)
+	copy(task.Windows[idx+1:], task.Windows[idx:])
+	task.Windows[idx] = window
+	return window
+}
+
+/*
+* Expire - remove the windows ending before the deadline
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   - deadline: windows ending before it are closed
+*
+* RETURNS:
+*   - []*Window: closed windows in order, the empty ones of zero-fill included
+ */
+func (task *AgentTask) Expire(deadline int64) []*Window {
+	var expired []*Window
+
+	idx := 0
+	for idx < len(task.Windows) && task.Windows[idx].TsEnd < deadline {
+		window := task.Windows[idx]
+		if task.ZeroFill && task.Flushed > 0 {
+			expired = append(expired, task.Gap(task.Flushed+1, window.TsStart)...)
+		}
+		expired = append(expired, window)
+		task.Flushed = window.TsEnd
+		if window.ValueCnt > 0 {
+			task.Active = window.TsEnd
+		}
+		idx++
+	}
+	task.Windows = task.Windows[idx:]
+
+	// an item silent in a busy log still reports its empty windows, the
+	// first deadline only marks where they begin
+	if task.ZeroFill && deadline < math.MaxInt64 {
+		to := task.WindowStart(deadline)
+		if task.Flushed > 0 {
+			expired = append(expired, task.Gap(task.Flushed+1, to)...)
+		}
+		if task.Flushed < to-1 {
+			task.Flushed = to - 1
+		}
+	}
+
+	if task.LateCnt > 0 && len(expired) > 0 {
+		log.Printf("%s dropped %d late lines", task.Metric, task.LateCnt)
+	}
+
+	return expired
+}
+
+/*
+* Gap - generate the empty windows between two timestamps
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   - from: start of the first empty window
+*   - to: start of the next window with data
+*
+* RETURNS:
+*   - []*Window: empty windows, the latest MAX_ZERO_FILL ones at most
+ */
+func (task *AgentTask) Gap(from int64, to int64) []*Window {
+	var windows []*Window
+
+	if task.Step <= 0 {
+		return windows
+	}
+	if (to-from)/task.Step > MAX_ZERO_FILL {
+		from = to - MAX_ZERO_FILL*task.Step
+	}
+	for start := from; start < to; start += task.Step {
+		windows = append(windows, NewWindow(start, task.Step, len(task.Thresholds)))
+	}
+	return windows
+}
+
+/*
+* Reset - drop the windows
+*
+* RECEIVER: *AgentTask
+*
+* PARAMS:
+*   No paramter
+*
+* RETURNS:
+*   No return value
+ */
+func (task *AgentTask) Reset() {
+	task.Windows = nil
+	task.Flushed = 0
+	task.LateCnt = 0
+	task.Series = nil
+}